@@ -0,0 +1,209 @@
+package eraf
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	armorVersion    = 1
+	armorLineLength = 64
+
+	armorHeaderVersion   = "Armor-Version"
+	armorHeaderKeyID     = "Key-ID"
+	armorHeaderEncrypted = "Encrypted"
+)
+
+var (
+	armorBegin = []byte("-----BEGIN ERAF CONTAINER-----")
+	armorEnd   = []byte("-----END ERAF CONTAINER-----")
+
+	// ErrArmorChecksum is returned if the checksum of an armored container does not match its content
+	ErrArmorChecksum = errors.New("armor checksum mismatch")
+)
+
+// Armor holds the headers of an ASCII armored container
+type Armor struct {
+	// KeyID optionally names the key the container has been encrypted with
+	KeyID string
+	// Encrypted states whether the fields of the container are encrypted. It is implied if the header flags
+	// of the container mark any field as encrypted.
+	Encrypted bool
+	// Headers holds additional headers, e.g. Comment
+	Headers map[string]string
+}
+
+// MarshalArmored serializes the container into a PEM-style text block which can safely be sent via email
+// or pasted into a terminal. The armor headers may be nil.
+func (c *Container) MarshalArmored(a *Armor) ([]byte, error) {
+	b, err := c.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	if a == nil {
+		a = &Armor{}
+	}
+	if strings.ContainsAny(a.KeyID, "\r\n") {
+		return nil, fmt.Errorf("invalid armor key id '%s'", a.KeyID)
+	}
+
+	var buf bytes.Buffer
+	buf.Write(armorBegin)
+	buf.WriteByte('\n')
+	fmt.Fprintf(&buf, "%s: %d\n", armorHeaderVersion, armorVersion)
+	if a.KeyID != "" {
+		fmt.Fprintf(&buf, "%s: %s\n", armorHeaderKeyID, a.KeyID)
+	}
+	encrypted := "no"
+	if a.Encrypted || c.IsEncrypted() {
+		encrypted = "yes"
+	}
+	fmt.Fprintf(&buf, "%s: %s\n", armorHeaderEncrypted, encrypted)
+
+	keys := make([]string, 0, len(a.Headers))
+	for k := range a.Headers {
+		if strings.ContainsAny(k, ":\r\n") || strings.ContainsAny(a.Headers[k], "\r\n") {
+			return nil, fmt.Errorf("invalid armor header '%s'", k)
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&buf, "%s: %s\n", k, a.Headers[k])
+	}
+	buf.WriteByte('\n')
+
+	encoded := base64.StdEncoding.EncodeToString(b)
+	for len(encoded) > armorLineLength {
+		buf.WriteString(encoded[:armorLineLength])
+		buf.WriteByte('\n')
+		encoded = encoded[armorLineLength:]
+	}
+	buf.WriteString(encoded)
+	buf.WriteByte('\n')
+
+	sum := crc24(b)
+	buf.WriteByte('=')
+	buf.WriteString(base64.StdEncoding.EncodeToString([]byte{byte(sum >> 16), byte(sum >> 8), byte(sum)}))
+	buf.WriteByte('\n')
+	buf.Write(armorEnd)
+	buf.WriteByte('\n')
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalArmored deserializes the first armored container found in b into target and returns its
+// armor headers. Text surrounding the armored block, indentation and Windows line endings are ignored.
+func UnmarshalArmored(b []byte, target *Container) (*Armor, error) {
+	start := bytes.Index(b, armorBegin)
+	if start < 0 {
+		return nil, fmt.Errorf("no armored container found")
+	}
+	s := bufio.NewScanner(bytes.NewReader(b[start+len(armorBegin):]))
+	s.Buffer(nil, len(b))
+	s.Scan() // rest of the BEGIN line
+
+	var (
+		a        = &Armor{}
+		body     bytes.Buffer
+		checksum string
+		inBody   bool
+		complete bool
+	)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == string(armorEnd) {
+			complete = true
+			break
+		}
+		switch {
+		case line == "":
+			inBody = true
+		case strings.HasPrefix(line, "="):
+			checksum = line[1:]
+		case !inBody && strings.Contains(line, ":"):
+			// base64 never contains a colon, so headers are recognized even without the separating blank line
+			parts := strings.SplitN(line, ":", 2)
+			if err := a.setHeader(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])); err != nil {
+				return nil, err
+			}
+		default:
+			inBody = true
+			body.WriteString(line)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if !complete {
+		return nil, fmt.Errorf("armored container is incomplete")
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(body.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid armored container: %w", err)
+	}
+	sum, err := base64.StdEncoding.DecodeString(checksum)
+	if err != nil || len(sum) != 3 {
+		return nil, fmt.Errorf("armored container has no valid checksum line")
+	}
+	if expected := crc24(raw); uint32(sum[0])<<16|uint32(sum[1])<<8|uint32(sum[2]) != expected {
+		return nil, ErrArmorChecksum
+	}
+
+	if err = UnmarshalNoCopy(raw, target); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *Armor) setHeader(key, value string) error {
+	switch key {
+	case armorHeaderVersion:
+		v, err := strconv.Atoi(value)
+		if err != nil || v < 1 || v > armorVersion {
+			return fmt.Errorf("unsupported armor version '%s'", value)
+		}
+	case armorHeaderKeyID:
+		a.KeyID = value
+	case armorHeaderEncrypted:
+		a.Encrypted = value == "yes"
+	default:
+		if a.Headers == nil {
+			a.Headers = make(map[string]string)
+		}
+		a.Headers[key] = value
+	}
+	return nil
+}
+
+// isArmored reports whether b looks like an armored container. Binary containers always start with a zero byte,
+// the position of the version block.
+func isArmored(b []byte) bool {
+	return len(b) > 0 && b[0] != 0 && bytes.Contains(b, armorBegin)
+}
+
+// crc24 calculates the checksum used by OpenPGP ASCII armor as specified in RFC 4880, section 6.1
+func crc24(b []byte) uint32 {
+	const (
+		crc24Init = 0xB704CE
+		crc24Poly = 0x1864CFB
+	)
+	crc := uint32(crc24Init)
+	for _, octet := range b {
+		crc ^= uint32(octet) << 16
+		for i := 0; i < 8; i++ {
+			crc <<= 1
+			if crc&0x1000000 != 0 {
+				crc ^= crc24Poly
+			}
+		}
+	}
+	return crc & 0xFFFFFF
+}
@@ -0,0 +1,141 @@
+package eraf
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_crc24(t *testing.T) {
+	// check value of the CRC-24 used by OpenPGP
+	if sum := crc24([]byte("123456789")); sum != 0x21CF02 {
+		t.Errorf("expected checksum 0x21cf02, got %#x", sum)
+	}
+}
+
+func Test_Container_MarshalArmored(t *testing.T) {
+	c := New().
+		SetIdentifier([]byte("device-42")).
+		SetCertificate(bytes.Repeat([]byte("certificate"), 20)).
+		SetPassword([]byte("pass"))
+
+	armored, err := c.MarshalArmored(&Armor{
+		KeyID:     "ops-2024",
+		Encrypted: true,
+		Headers:   map[string]string{"Comment": "test device"},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	for _, line := range strings.Split(string(armored), "\n") {
+		if len(line) > armorLineLength {
+			t.Errorf("expected lines of at most %d characters, got %d", armorLineLength, len(line))
+		}
+	}
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"plain", string(armored)},
+		{"surrounding text", "Hi,\n\nhere are your credentials:\n\n" + string(armored) + "\nRegards\n"},
+		{"windows line endings", strings.ReplaceAll(string(armored), "\n", "\r\n")},
+		{"indented", "    " + strings.ReplaceAll(string(armored), "\n", "\n    ")},
+		{"no blank line after headers", strings.Replace(string(armored), "\n\n", "\n", 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := &Container{}
+			a, err := UnmarshalArmored([]byte(tt.input), result)
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err.Error())
+			}
+			if !bytes.Equal(result.MarshalBytes(), c.MarshalBytes()) {
+				t.Errorf("expected identical container after armor round trip")
+			}
+			if a.KeyID != "ops-2024" || !a.Encrypted || a.Headers["Comment"] != "test device" {
+				t.Errorf("expected armor headers to be preserved, got %+v", a)
+			}
+		})
+	}
+}
+
+func Test_Container_MarshalArmored_Injection(t *testing.T) {
+	for _, a := range []*Armor{
+		{KeyID: "ops-2024\nEncrypted: no"},
+		{KeyID: "ops-2024\r"},
+		{Headers: map[string]string{"Comment": "test\nEncrypted: no"}},
+		{Headers: map[string]string{"Encrypted: no\nComment": "test"}},
+	} {
+		if _, err := New().MarshalArmored(a); err == nil {
+			t.Errorf("expected error for line break in armor headers %+v", a)
+		}
+	}
+}
+
+func Test_UnmarshalArmored_Invalid(t *testing.T) {
+	armored, err := New().SetEmail([]byte("someone@example.com")).MarshalArmored(nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	lines := strings.Split(string(armored), "\n")
+
+	corrupted := append([]string{}, lines...)
+	body := []byte(corrupted[4])
+	body[10] ^= 'A' ^ 'B'
+	corrupted[4] = string(body)
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"empty", ""},
+		{"no end line", strings.Join(lines[:len(lines)-2], "\n")},
+		{"no checksum", strings.Join(append(lines[:len(lines)-3:len(lines)-3], lines[len(lines)-2:]...), "\n")},
+		{"unsupported version", strings.Replace(string(armored), "Armor-Version: 1", "Armor-Version: 2", 1)},
+		{"corrupted body", strings.Join(corrupted, "\n")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := UnmarshalArmored([]byte(tt.input), &Container{}); err == nil {
+				t.Errorf("expected an error, got nil")
+			}
+		})
+	}
+
+	if _, err = UnmarshalArmored([]byte(strings.Join(corrupted, "\n")), &Container{}); !errors.Is(err, ErrArmorChecksum) {
+		t.Errorf("expected ErrArmorChecksum, got %v", err)
+	}
+}
+
+func Test_UnmarshalFromFile_Armored(t *testing.T) {
+	c := New().SetUsername([]byte("someone"))
+	armored, err := c.MarshalArmored(nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	file := filepath.Join(t.TempDir(), "armored.eraf")
+	if err = ioutil.WriteFile(file, armored, 0600); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	result := &Container{}
+	if err = UnmarshalFromFile(file, result); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if string(result.GetUsername()) != "someone" {
+		t.Errorf("expected username 'someone', got '%s'", result.GetUsername())
+	}
+
+	// a binary container holding an armored container in one of its fields is still read as binary
+	outer := New().SetToken(armored)
+	if err = Unmarshal(bytes.NewReader(outer.MarshalBytes()), result); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if !bytes.Equal(result.GetToken(), armored) {
+		t.Errorf("expected binary container with armored token")
+	}
+}
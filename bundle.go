@@ -0,0 +1,364 @@
+package eraf
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// A bundle holds many containers in a single file, followed by an index, so a single container can be read
+// without parsing the others:
+//
+//	header, 16 bytes:
+//	  8 bytes: magic ERAFBNDL
+//	  1 byte:  format version
+//	  1 byte:  flags, bit 0 is set if the bundle is encrypted
+//	  6 bytes: reserved, zero
+//	entries: the marshalled containers, back to back
+//	index:
+//	  4 bytes: number of entries
+//	  per entry:
+//	    2 bytes: identifier length, followed by the identifier
+//	    2 bytes: serial number length, followed by the serial number
+//	    8 bytes: offset of the entry
+//	    4 bytes: length of the entry
+//	trailer, 16 bytes:
+//	  8 bytes: offset of the index
+//	  4 bytes: length of the index
+//	  4 bytes: magic BNDX
+//
+// In encrypted bundles, every entry and the index are encrypted using AES-GCM, each with a random nonce which
+// is prepended. The header and the offset are used as additional data, so entries cannot be moved around.
+// All integers are big endian.
+const (
+	bundleVersion     byte = 1
+	bundleHeaderSize       = 16
+	bundleTrailerSize      = 16
+
+	bundleFlagEncrypted byte = 1 << 0
+)
+
+var (
+	bundleMagic        = []byte("ERAFBNDL")
+	bundleTrailerMagic = []byte("BNDX")
+)
+
+// BundleEntry describes a container in a bundle
+type BundleEntry struct {
+	Identifier   []byte
+	SerialNumber []byte
+	// Offset and Length locate the entry within the bundle
+	Offset int64
+	Length int64
+}
+
+// BundleFile is a file a *BundleWriter can append to, e.g. an *os.File opened for reading and writing
+type BundleFile interface {
+	io.ReaderAt
+	io.WriteSeeker
+	Truncate(size int64) error
+}
+
+// BundleWriter writes containers into a bundle. Close has to be called to write the index.
+type BundleWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	header  [bundleHeaderSize]byte
+	offset  int64
+	entries []BundleEntry
+	closed  bool
+}
+
+// NewBundleWriter writes the header of a new bundle into w. If key is not nil, the bundle is encrypted using
+// AES-128, AES-192 or AES-256, depending on key length.
+func NewBundleWriter(w io.Writer, key []byte) (*BundleWriter, error) {
+	bw := &BundleWriter{w: w}
+	copy(bw.header[:], bundleMagic)
+	bw.header[8] = bundleVersion
+	if key != nil {
+		bw.header[9] |= bundleFlagEncrypted
+		aead, err := newGcm(key)
+		if err != nil {
+			return nil, err
+		}
+		bw.aead = aead
+	}
+
+	if _, err := w.Write(bw.header[:]); err != nil {
+		return nil, err
+	}
+	bw.offset = bundleHeaderSize
+	return bw, nil
+}
+
+// AppendBundle opens an existing bundle for appending. The index is read and removed from the file, so Close
+// has to be called to write it again. The key has to match the one the bundle has been created with.
+func AppendBundle(f BundleFile, key []byte) (*BundleWriter, error) {
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	br, err := NewBundleReader(f, size, key)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = f.Seek(br.indexOffset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if err = f.Truncate(br.indexOffset); err != nil {
+		return nil, err
+	}
+
+	return &BundleWriter{
+		w:       f,
+		aead:    br.aead,
+		header:  br.header,
+		offset:  br.indexOffset,
+		entries: br.entries,
+	}, nil
+}
+
+// Add appends the container to the bundle. Containers may share identifier and serial number, in which case
+// the one added last is returned by BundleReader.Get.
+func (bw *BundleWriter) Add(c *Container) error {
+	if bw.closed {
+		return fmt.Errorf("bundle writer is closed")
+	}
+	b, err := c.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if b, err = bw.seal(b, bw.offset); err != nil {
+		return err
+	}
+	if _, err = bw.w.Write(b); err != nil {
+		return err
+	}
+
+	bw.entries = append(bw.entries, BundleEntry{
+		Identifier:   append([]byte(nil), c.fields[FieldIdentifier]...),
+		SerialNumber: append([]byte(nil), c.fields[FieldSerialNumber]...),
+		Offset:       bw.offset,
+		Length:       int64(len(b)),
+	})
+	bw.offset += int64(len(b))
+	return nil
+}
+
+// Close writes the index and the trailer. The underlying writer is not closed.
+func (bw *BundleWriter) Close() error {
+	if bw.closed {
+		return nil
+	}
+	bw.closed = true
+
+	index := binary.BigEndian.AppendUint32(nil, uint32(len(bw.entries)))
+	for _, e := range bw.entries {
+		index = binary.BigEndian.AppendUint16(index, uint16(len(e.Identifier)))
+		index = append(index, e.Identifier...)
+		index = binary.BigEndian.AppendUint16(index, uint16(len(e.SerialNumber)))
+		index = append(index, e.SerialNumber...)
+		index = binary.BigEndian.AppendUint64(index, uint64(e.Offset))
+		index = binary.BigEndian.AppendUint32(index, uint32(e.Length))
+	}
+	index, err := bw.seal(index, bw.offset)
+	if err != nil {
+		return err
+	}
+
+	length := len(index)
+	index = binary.BigEndian.AppendUint64(index, uint64(bw.offset))
+	index = binary.BigEndian.AppendUint32(index, uint32(length))
+	index = append(index, bundleTrailerMagic...)
+	_, err = bw.w.Write(index)
+	return err
+}
+
+func (bw *BundleWriter) seal(b []byte, offset int64) ([]byte, error) {
+	if bw.aead == nil {
+		return b, nil
+	}
+	nonce := make([]byte, bw.aead.NonceSize(), bw.aead.NonceSize()+len(b)+bw.aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return bw.aead.Seal(nonce, nonce, b, bundleAdditionalData(bw.header, offset)), nil
+}
+
+// BundleReader reads single containers from a bundle. Only the header, the trailer and the index are read
+// when it is created, entries are read on demand.
+type BundleReader struct {
+	r           io.ReaderAt
+	aead        cipher.AEAD
+	header      [bundleHeaderSize]byte
+	entries     []BundleEntry
+	indexOffset int64
+}
+
+// NewBundleReader reads the index of the bundle of the given size from r, e.g. an *os.File. The key is required
+// if the bundle is encrypted.
+func NewBundleReader(r io.ReaderAt, size int64, key []byte) (*BundleReader, error) {
+	br := &BundleReader{r: r}
+	if size < bundleHeaderSize+bundleTrailerSize {
+		return nil, fmt.Errorf("bundle is too short")
+	}
+	if err := readFullAt(r, br.header[:], 0); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(br.header[:8], bundleMagic) {
+		return nil, fmt.Errorf("not a bundle")
+	}
+	if br.header[8] != bundleVersion {
+		return nil, fmt.Errorf("unsupported bundle version %d", br.header[8])
+	}
+
+	encrypted := br.header[9]&bundleFlagEncrypted != 0
+	switch {
+	case encrypted && key == nil:
+		return nil, fmt.Errorf("bundle is encrypted, but no key is given")
+	case !encrypted && key != nil:
+		return nil, fmt.Errorf("bundle is not encrypted")
+	case encrypted:
+		aead, err := newGcm(key)
+		if err != nil {
+			return nil, err
+		}
+		br.aead = aead
+	}
+
+	var trailer [bundleTrailerSize]byte
+	if err := readFullAt(r, trailer[:], size-bundleTrailerSize); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(trailer[12:], bundleTrailerMagic) {
+		return nil, fmt.Errorf("bundle index is missing, the bundle may be truncated")
+	}
+	br.indexOffset = int64(binary.BigEndian.Uint64(trailer[:8]))
+	indexLength := int64(binary.BigEndian.Uint32(trailer[8:12]))
+	// the offset is read from the file, so it is compared without adding to it, which could overflow
+	if br.indexOffset < bundleHeaderSize || br.indexOffset > size-bundleTrailerSize ||
+		indexLength != size-bundleTrailerSize-br.indexOffset {
+		return nil, fmt.Errorf("bundle index exceeds bundle")
+	}
+
+	index, err := br.read(br.indexOffset, indexLength)
+	if err != nil {
+		return nil, fmt.Errorf("could not read bundle index: %w", err)
+	}
+	if br.entries, err = parseBundleIndex(index, br.indexOffset); err != nil {
+		return nil, err
+	}
+	return br, nil
+}
+
+// Len returns the number of entries
+func (br *BundleReader) Len() int {
+	return len(br.entries)
+}
+
+// Entries returns the index of the bundle in the order the containers have been added
+func (br *BundleReader) Entries() []BundleEntry {
+	return append([]BundleEntry(nil), br.entries...)
+}
+
+// Container reads the i-th entry
+func (br *BundleReader) Container(i int) (*Container, error) {
+	if i < 0 || i >= len(br.entries) {
+		return nil, fmt.Errorf("bundle entry %d out of range", i)
+	}
+	b, err := br.read(br.entries[i].Offset, br.entries[i].Length)
+	if err != nil {
+		return nil, err
+	}
+	c := New()
+	if err = UnmarshalNoCopy(b, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Get reads the container with the given identifier and serial number. If there are several, the one added last is
+// returned. ErrNotFound is returned if there is none.
+func (br *BundleReader) Get(identifier, serialNumber []byte) (*Container, error) {
+	for i := len(br.entries) - 1; i >= 0; i-- {
+		if bytes.Equal(br.entries[i].Identifier, identifier) && bytes.Equal(br.entries[i].SerialNumber, serialNumber) {
+			return br.Container(i)
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// read reads and, if required, decrypts the given range
+func (br *BundleReader) read(offset, length int64) ([]byte, error) {
+	b := make([]byte, length)
+	if err := readFullAt(br.r, b, offset); err != nil {
+		return nil, err
+	}
+	if br.aead == nil {
+		return b, nil
+	}
+	if len(b) < br.aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	n := br.aead.NonceSize()
+	return br.aead.Open(b[n:n], b[:n], b[n:], bundleAdditionalData(br.header, offset))
+}
+
+func parseBundleIndex(index []byte, indexOffset int64) ([]BundleEntry, error) {
+	errTruncated := errors.New("bundle index is truncated")
+	if len(index) < 4 {
+		return nil, errTruncated
+	}
+	count := binary.BigEndian.Uint32(index)
+	index = index[4:]
+
+	var entries []BundleEntry
+	for i := uint32(0); i < count; i++ {
+		var e BundleEntry
+		for _, target := range []*[]byte{&e.Identifier, &e.SerialNumber} {
+			if len(index) < 2 {
+				return nil, errTruncated
+			}
+			l := int(binary.BigEndian.Uint16(index))
+			if len(index) < 2+l {
+				return nil, errTruncated
+			}
+			*target = index[2 : 2+l]
+			index = index[2+l:]
+		}
+		if len(index) < 12 {
+			return nil, errTruncated
+		}
+		e.Offset = int64(binary.BigEndian.Uint64(index))
+		e.Length = int64(binary.BigEndian.Uint32(index[8:]))
+		index = index[12:]
+		if e.Offset < bundleHeaderSize || e.Offset > indexOffset || e.Length > indexOffset-e.Offset {
+			return nil, fmt.Errorf("bundle entry %d exceeds bundle", i)
+		}
+		entries = append(entries, e)
+	}
+	if len(index) > 0 {
+		return nil, fmt.Errorf("trailing data after bundle index")
+	}
+	return entries, nil
+}
+
+func bundleAdditionalData(header [bundleHeaderSize]byte, offset int64) []byte {
+	return binary.BigEndian.AppendUint64(header[:], uint64(offset))
+}
+
+// readFullAt reads exactly len(b) bytes, accepting io.EOF if it is returned along with all of them
+func readFullAt(r io.ReaderAt, b []byte, offset int64) error {
+	n, err := r.ReadAt(b, offset)
+	if n == len(b) {
+		return nil
+	}
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
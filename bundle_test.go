@@ -0,0 +1,197 @@
+package eraf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// countingReaderAt records how many bytes have been read
+type countingReaderAt struct {
+	r *bytes.Reader
+	n int
+}
+
+func (c *countingReaderAt) ReadAt(b []byte, off int64) (int, error) {
+	n, err := c.r.ReadAt(b, off)
+	c.n += n
+	return n, err
+}
+
+func bundleContainers() []*Container {
+	return []*Container{
+		New().SetIdentifier([]byte("device-1")).SetSerialNumber([]byte{1}).SetEmail([]byte("one@example.com")),
+		New().SetIdentifier([]byte("device-2")).SetSerialNumber([]byte{2}).SetCertificate(make([]byte, 4096)),
+		New().SetIdentifier([]byte("device-3")).SetSerialNumber([]byte{3}).SetToken([]byte("token")),
+	}
+}
+
+func Test_Bundle(t *testing.T) {
+	tests := []struct {
+		name string
+		key  []byte
+	}{
+		{"plain", nil},
+		{"encrypted", []byte("0123456789abcdef0123456789abcdef")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			bw, err := NewBundleWriter(&buf, tt.key)
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err.Error())
+			}
+			for _, c := range bundleContainers() {
+				if err = bw.Add(c); err != nil {
+					t.Fatalf("expected no error, got %s", err.Error())
+				}
+			}
+			if err = bw.Close(); err != nil {
+				t.Fatalf("expected no error, got %s", err.Error())
+			}
+			if tt.key != nil && bytes.Contains(buf.Bytes(), []byte("device-2")) {
+				t.Errorf("expected index to be encrypted")
+			}
+
+			r := &countingReaderAt{r: bytes.NewReader(buf.Bytes())}
+			br, err := NewBundleReader(r, int64(buf.Len()), tt.key)
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err.Error())
+			}
+			if br.Len() != 3 || string(br.Entries()[1].Identifier) != "device-2" {
+				t.Fatalf("expected 3 entries, got %d", br.Len())
+			}
+
+			c, err := br.Get([]byte("device-3"), []byte{3})
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err.Error())
+			}
+			if string(c.GetToken()) != "token" {
+				t.Errorf("expected 'token', got '%s'", c.GetToken())
+			}
+			if r.n >= buf.Len()-4096 {
+				t.Errorf("expected other entries not to be read, read %d of %d bytes", r.n, buf.Len())
+			}
+
+			if _, err = br.Get([]byte("device-4"), []byte{4}); !errors.Is(err, ErrNotFound) {
+				t.Errorf("expected ErrNotFound, got %v", err)
+			}
+			if _, err = br.Container(3); err == nil {
+				t.Errorf("expected error for entry out of range")
+			}
+		})
+	}
+}
+
+func Test_Bundle_Invalid(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	var buf bytes.Buffer
+	bw, _ := NewBundleWriter(&buf, key)
+	_ = bw.Add(bundleContainers()[0])
+	_ = bw.Close()
+	b := buf.Bytes()
+
+	tampered := append([]byte(nil), b...)
+	tampered[bundleHeaderSize+20] ^= 1
+
+	tests := []struct {
+		name string
+		b    []byte
+		key  []byte
+	}{
+		{"no key", b, nil},
+		{"wrong key", b, []byte("fedcba9876543210")},
+		{"truncated", b[:len(b)-1], key},
+		{"too short", b[:20], key},
+		{"not a bundle", append([]byte("ERAFBNDX"), b[8:]...), key},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewBundleReader(bytes.NewReader(tt.b), int64(len(tt.b)), tt.key); err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+
+	br, err := NewBundleReader(bytes.NewReader(tampered), int64(len(tampered)), key)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if _, err = br.Container(0); err == nil {
+		t.Errorf("expected error for tampered entry")
+	}
+
+	// an entry offset close to the maximum int64 must not wrap around when the length is added
+	index := binary.BigEndian.AppendUint32(nil, 1)
+	index = append(index, 0, 0, 0, 0)
+	index = binary.BigEndian.AppendUint64(index, math.MaxInt64-10)
+	index = binary.BigEndian.AppendUint32(index, 100)
+	if _, err := parseBundleIndex(index, 1000); err == nil {
+		t.Errorf("expected error for overflowing entry")
+	}
+}
+
+func Test_AppendBundle(t *testing.T) {
+	var (
+		key        = []byte("0123456789abcdef")
+		file       = filepath.Join(t.TempDir(), "bundle.erafb")
+		containers = bundleContainers()
+	)
+
+	f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer f.Close()
+
+	bw, err := NewBundleWriter(f, key)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	_ = bw.Add(containers[0])
+	_ = bw.Add(containers[1])
+	if err = bw.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if bw, err = AppendBundle(f, key); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	_ = bw.Add(containers[2])
+	_ = bw.Add(New().SetIdentifier([]byte("device-1")).SetSerialNumber([]byte{1}).SetEmail([]byte("new@example.com")))
+	if err = bw.Close(); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	br, err := NewBundleReader(f, info.Size(), key)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if br.Len() != 4 {
+		t.Fatalf("expected 4 entries, got %d", br.Len())
+	}
+	for i, c := range containers {
+		got, err := br.Container(i)
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err.Error())
+		}
+		if !bytes.Equal(got.MarshalBytes(), c.MarshalBytes()) {
+			t.Errorf("expected entry %d to equal the original container", i)
+		}
+	}
+	if c, _ := br.Get([]byte("device-1"), []byte{1}); c == nil || string(c.GetEmail()) != "new@example.com" {
+		t.Errorf("expected the container added last to be returned")
+	}
+
+	if _, err = AppendBundle(f, nil); err == nil {
+		t.Errorf("expected error appending without key")
+	}
+}
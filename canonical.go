@@ -0,0 +1,94 @@
+package eraf
+
+import (
+	"crypto"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// The canonical form of a container is a deterministic encoding which only depends on its contents, so it can be
+// hashed and signed. It does not depend on how the container is stored, e.g. compression, the checksum trailer or
+// the order extensions have been added in, and it is stable across versions of this SDK:
+//
+//	4 bytes: magic ERAF
+//	1 byte:  version of the canonical form, currently 1
+//	3 bytes: major, minor and patch version
+//	per field in wire order, except the signature:
+//	  1 byte:  1 if the field is present, 0 if it is absent
+//	  if present, 4 bytes length followed by the value
+//	4 bytes: number of extensions with Sign set
+//	per extension with Sign set, numeric keys in ascending order first, then string keys in byte order:
+//	  1 byte:  1 for numeric keys, 0 for string keys
+//	  key:     4 bytes for numeric keys, 1 byte length followed by the name for string keys
+//	  4 bytes: value length, followed by the value
+//
+// Encrypted fields and extensions are included as they are, i.e. encrypted. All integers are big endian.
+const canonicalVersion byte = 1
+
+var canonicalMagic = []byte("ERAF")
+
+// CanonicalBytes returns the canonical form of the container, which is suitable for computing and verifying the
+// signature. The signature field itself is not included, so it can be set afterwards.
+func (c *Container) CanonicalBytes() []byte {
+	b := append([]byte(nil), canonicalMagic...)
+	b = append(b, canonicalVersion, c.versionMajor, c.versionMinor, c.versionPatch)
+
+	for id, f := range c.fields {
+		if FieldID(id) == FieldSignature {
+			continue
+		}
+		if f == nil {
+			b = append(b, 0)
+			continue
+		}
+		b = append(b, 1)
+		b = binary.BigEndian.AppendUint32(b, uint32(len(f)))
+		b = append(b, f...)
+	}
+
+	var signed []Extension
+	for _, e := range c.extensions {
+		if e.Sign {
+			signed = append(signed, e)
+		}
+	}
+	sort.SliceStable(signed, func(i, j int) bool {
+		return extensionKeyLess(signed[i].Key, signed[j].Key)
+	})
+	b = binary.BigEndian.AppendUint32(b, uint32(len(signed)))
+	for _, e := range signed {
+		if e.Key.numeric {
+			b = append(b, 1)
+			b = binary.BigEndian.AppendUint32(b, e.Key.number)
+		} else {
+			b = append(b, 0, byte(len(e.Key.name)))
+			b = append(b, e.Key.name...)
+		}
+		b = binary.BigEndian.AppendUint32(b, uint32(len(e.Value)))
+		b = append(b, e.Value...)
+	}
+
+	return b
+}
+
+// Fingerprint returns the hash of the canonical form of the container, e.g. using crypto.SHA256, which can be used
+// as a stable ID to reference or deduplicate containers. The hash function has to be linked into the binary.
+func (c *Container) Fingerprint(h crypto.Hash) ([]byte, error) {
+	if !h.Available() {
+		return nil, fmt.Errorf("hash function %s is not available", h)
+	}
+	hash := h.New()
+	_, _ = hash.Write(c.CanonicalBytes())
+	return hash.Sum(nil), nil
+}
+
+func extensionKeyLess(a, b ExtensionKey) bool {
+	if a.numeric != b.numeric {
+		return a.numeric
+	}
+	if a.numeric {
+		return a.number < b.number
+	}
+	return a.name < b.name
+}
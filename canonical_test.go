@@ -0,0 +1,70 @@
+package eraf
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/hex"
+	"testing"
+)
+
+func Test_Container_CanonicalBytes(t *testing.T) {
+	c := New().SetVersionMajor(1).SetEmail([]byte("a@b")).SetToken([]byte{}).SetSignature([]byte("sig"))
+	_ = c.SetExtension(Extension{Key: StringKey("tenant"), Value: []byte("x"), Sign: true})
+	_ = c.SetExtension(Extension{Key: NumericKey(7), Value: []byte("y"), Sign: true})
+	_ = c.SetExtension(Extension{Key: StringKey("unsigned"), Value: []byte("z")})
+
+	expected := "4552414601010000" + // magic, canonical version, version 1.0.0
+		"000000000000" + // nonce, tag, serial number, identifier, certificate and private key absent
+		"010000000361406200" + // email, absent username
+		"0100000000" + // empty token, signature omitted
+		"0000" + // absent root certificate and password
+		"00000002" + // two signed extensions, numeric first
+		"01000000070000000179" +
+		"000674656e616e74" + "0000000178"
+	if got := hex.EncodeToString(c.CanonicalBytes()); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+
+	// storage details, the signature and unsigned extensions do not matter
+	other := New().SetVersionMajor(1).SetChecksum(ChecksumCRC32C).SetSigned(true)
+	_ = other.SetCompression(FieldEmail, true)
+	_ = other.SetExtension(Extension{Key: StringKey("tenant"), Value: []byte("x"), Sign: true})
+	_ = other.SetExtension(Extension{Key: NumericKey(7), Value: []byte("y"), Sign: true})
+	other.SetEmail([]byte("a@b")).SetToken([]byte{})
+	if !bytes.Equal(other.CanonicalBytes(), c.CanonicalBytes()) {
+		t.Errorf("expected identical canonical form")
+	}
+
+	result := &Container{}
+	if err := UnmarshalBytes(other.MarshalBytes(), result); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if !bytes.Equal(result.CanonicalBytes(), c.CanonicalBytes()) {
+		t.Errorf("expected identical canonical form after unmarshalling")
+	}
+
+	if bytes.Equal(New().SetToken([]byte{}).CanonicalBytes(), New().CanonicalBytes()) {
+		t.Errorf("expected empty and absent fields to differ")
+	}
+}
+
+func Test_Container_Fingerprint(t *testing.T) {
+	c := New().SetIdentifier([]byte("device-42"))
+	fp, err := c.Fingerprint(crypto.SHA256)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	expected := sha256.Sum256(c.CanonicalBytes())
+	if !bytes.Equal(fp, expected[:]) {
+		t.Errorf("expected SHA-256 of the canonical form")
+	}
+
+	if fp, err = c.Fingerprint(crypto.SHA512); err != nil || len(fp) != 64 {
+		t.Errorf("expected SHA-512 fingerprint, got %d bytes and %v", len(fp), err)
+	}
+	if _, err = c.Fingerprint(crypto.MD4); err == nil {
+		t.Errorf("expected error for unavailable hash function")
+	}
+}
@@ -0,0 +1,259 @@
+package eraf
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// CBOR major types as specified in RFC 8949, section 3.1
+const (
+	cborUnsigned   byte = 0
+	cborByteString byte = 2
+	cborArray      byte = 4
+	cborMap        byte = 5
+)
+
+// cborKeyVersion is the map key of the version array [major, minor, patch]. The fields use their FieldID + 1 as key,
+// followed by the keys of the meta data.
+const (
+	cborKeyVersion    = 0
+	cborKeyFlags      = int(fieldCount) + 1
+	cborKeyExtensions = cborKeyFlags + 1
+	cborKeyCompressed = cborKeyExtensions + 1
+	cborKeyMax        = cborKeyCompressed
+)
+
+var errCBORTruncated = errors.New("cbor: unexpected end of data")
+
+// MarshalCBOR serializes the container into a CBOR map with small integer keys, using the deterministic
+// encoding of RFC 8949, section 4.2.1:
+//
+//	0: [major, minor, patch]
+//	1: nonce, 2: tag, 3: serial number, 4: identifier, 5: certificate, 6: private key,
+//	7: email, 8: username, 9: token, 10: signature, 11: root certificate, 12: password
+//	13: flags, 14: extensions, 15: compression bitmap
+//
+// All fields are byte strings. Absent fields are omitted. Compressed fields are stored uncompressed unless they
+// are encrypted. The flags word describing encryption and signing and the compression bitmap are unsigned
+// integers, omitted if they are zero. The extensions are a byte string holding the extension area like in the
+// binary format, omitted if there are none.
+func (c *Container) MarshalCBOR() ([]byte, error) {
+	if err := c.checkSize(); err != nil {
+		return nil, err
+	}
+
+	n, size := 1, 32
+	for _, f := range c.fields {
+		if f != nil {
+			n++
+			size += 6 + len(f)
+		}
+	}
+	if c.flags != 0 {
+		n++
+	}
+	extensions := c.marshalExtensions()
+	if extensions != nil {
+		n++
+	}
+	if c.compressed != 0 {
+		n++
+	}
+
+	b := make([]byte, 0, size+len(extensions))
+	b = cborAppendHead(b, cborMap, uint64(n))
+	b = cborAppendHead(b, cborUnsigned, cborKeyVersion)
+	b = cborAppendHead(b, cborArray, 3)
+	b = cborAppendHead(b, cborUnsigned, uint64(c.versionMajor))
+	b = cborAppendHead(b, cborUnsigned, uint64(c.versionMinor))
+	b = cborAppendHead(b, cborUnsigned, uint64(c.versionPatch))
+	for id, f := range c.fields {
+		if f == nil {
+			continue
+		}
+		b = cborAppendHead(b, cborUnsigned, uint64(id+1))
+		b = cborAppendHead(b, cborByteString, uint64(len(f)))
+		b = append(b, f...)
+	}
+	if c.flags != 0 {
+		b = cborAppendHead(b, cborUnsigned, uint64(cborKeyFlags))
+		b = cborAppendHead(b, cborUnsigned, uint64(c.flags))
+	}
+	if extensions != nil {
+		b = cborAppendHead(b, cborUnsigned, uint64(cborKeyExtensions))
+		b = cborAppendHead(b, cborByteString, uint64(len(extensions)))
+		b = append(b, extensions...)
+	}
+	if c.compressed != 0 {
+		b = cborAppendHead(b, cborUnsigned, uint64(cborKeyCompressed))
+		b = cborAppendHead(b, cborUnsigned, uint64(c.compressed))
+	}
+	return b, nil
+}
+
+// UnmarshalCBOR deserializes a CBOR map as written by MarshalCBOR into the container, copying all fields.
+// Decoding is strict: anything but the deterministic encoding, e.g. unsorted or duplicate keys, indefinite
+// lengths, unknown keys or fields exceeding 65,535 bytes, or the maximum decompressed size of the container if
+// they are compressed, is rejected. The settings of the container, e.g. defensive copies, are kept.
+func (c *Container) UnmarshalCBOR(b []byte) error {
+	d := &cborDecoder{b: b}
+	n, err := d.head(cborMap)
+	if err != nil {
+		return err
+	}
+	result := c.decodeTarget()
+	if n > uint64(cborKeyMax)+1 {
+		return fmt.Errorf("cbor: too many map entries")
+	}
+
+	last := -1
+	for i := uint64(0); i < n; i++ {
+		k, err := d.head(cborUnsigned)
+		if err != nil {
+			return err
+		}
+		if k > uint64(cborKeyMax) {
+			return fmt.Errorf("cbor: unknown key %d", k)
+		}
+		key := int(k)
+		if key <= last {
+			return fmt.Errorf("cbor: map keys not in ascending order")
+		}
+		last = key
+
+		if key == cborKeyVersion {
+			if l, err := d.head(cborArray); err != nil {
+				return err
+			} else if l != 3 {
+				return fmt.Errorf("cbor: version must have 3 elements")
+			}
+			var version [3]byte
+			for j := range version {
+				v, err := d.head(cborUnsigned)
+				if err != nil {
+					return err
+				}
+				if v > 255 {
+					return fmt.Errorf("cbor: version element %d out of range", v)
+				}
+				version[j] = byte(v)
+			}
+			result.SetVersionMajor(version[0]).SetVersionMinor(version[1]).SetVersionPatch(version[2])
+			continue
+		}
+		if key == cborKeyFlags {
+			flags, err := d.head(cborUnsigned)
+			if err != nil {
+				return err
+			}
+			if flags > math.MaxUint32 {
+				return fmt.Errorf("cbor: flags %d out of range", flags)
+			}
+			// the extension and compression flags are derived on marshalling
+			result.flags = uint32(flags) &^ (flagExtensions | flagCompressed)
+			continue
+		}
+		if key == cborKeyCompressed {
+			compressed, err := d.head(cborUnsigned)
+			if err != nil {
+				return err
+			}
+			if compressed > math.MaxUint16 {
+				return fmt.Errorf("cbor: compression bitmap %d out of range", compressed)
+			}
+			result.compressed = uint16(compressed)
+			continue
+		}
+
+		l, err := d.head(cborByteString)
+		if err != nil {
+			return err
+		}
+		if key == cborKeyExtensions {
+			if l > uint64(len(d.b)-d.off) {
+				return errCBORTruncated
+			}
+			if result.extensions, err = unmarshalExtensions(append([]byte{}, d.b[d.off:d.off+int(l)]...)); err != nil {
+				return err
+			}
+			d.off += int(l)
+			continue
+		}
+		if l > uint64(len(d.b)-d.off) {
+			return errCBORTruncated
+		}
+		result.fields[key-1] = append([]byte{}, d.b[d.off:d.off+int(l)]...)
+		d.off += int(l)
+	}
+	if d.off != len(d.b) {
+		return fmt.Errorf("cbor: trailing data")
+	}
+	// the compression bitmap follows the fields, so their sizes are checked last
+	for id, f := range result.fields {
+		if max := result.maxFieldSize(FieldID(id)); len(f) > max {
+			return &ErrFieldTooLarge{Field: FieldID(id), Size: len(f), Limit: max}
+		}
+	}
+
+	c.assign(result)
+	return nil
+}
+
+// cborAppendHead appends the initial byte and the shortest possible encoding of the argument
+func cborAppendHead(b []byte, major byte, n uint64) []byte {
+	major <<= 5
+	switch {
+	case n < 24:
+		return append(b, major|byte(n))
+	case n <= 0xff:
+		return append(b, major|24, byte(n))
+	case n <= 0xffff:
+		return append(b, major|25, byte(n>>8), byte(n))
+	case n <= 0xffffffff:
+		return append(b, major|26, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	default:
+		return append(b, major|27, byte(n>>56), byte(n>>48), byte(n>>40), byte(n>>32),
+			byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+}
+
+type cborDecoder struct {
+	b   []byte
+	off int
+}
+
+// head reads the initial byte and argument of the next data item, which has to be of the given major type
+// and use the shortest possible encoding
+func (d *cborDecoder) head(major byte) (uint64, error) {
+	if d.off >= len(d.b) {
+		return 0, errCBORTruncated
+	}
+	ib := d.b[d.off]
+	d.off++
+	if ib>>5 != major {
+		return 0, fmt.Errorf("cbor: expected major type %d, got %d", major, ib>>5)
+	}
+
+	info := ib & 0x1f
+	if info < 24 {
+		return uint64(info), nil
+	}
+	if info > 27 {
+		return 0, fmt.Errorf("cbor: indefinite length or reserved value not allowed")
+	}
+	size := 1 << (info - 24)
+	if len(d.b)-d.off < size {
+		return 0, errCBORTruncated
+	}
+	var n uint64
+	for _, x := range d.b[d.off : d.off+size] {
+		n = n<<8 | uint64(x)
+	}
+	d.off += size
+
+	if shortest := [...]uint64{24, 0x100, 0x10000, 0x100000000}[info-24]; n < shortest {
+		return 0, fmt.Errorf("cbor: non-deterministic encoding of %d", n)
+	}
+	return n, nil
+}
@@ -0,0 +1,106 @@
+package eraf
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func Test_Container_MarshalCBOR(t *testing.T) {
+	c := New().SetIdentifier([]byte("dev")).SetPassword(bytes.Repeat([]byte{1}, 300))
+	c.SetVersionMajor(1).SetVersionMinor(2).SetVersionPatch(30)
+
+	b, err := c.MarshalCBOR()
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	expected := []byte{
+		0xa3,                       // map(3)
+		0x00, 0x83, 1, 2, 0x18, 30, // 0: [1, 2, 30]
+		0x04, 0x43, 'd', 'e', 'v', // 4: h'646576'
+		0x0c, 0x59, 0x01, 0x2c, // 12: bytes(300)
+	}
+	if !bytes.HasPrefix(b, expected) || len(b) != len(expected)+300 {
+		t.Errorf("expected %x..., got %x", expected, b[:len(expected)])
+	}
+
+	result := New().SetEmail([]byte("overwritten"))
+	if err = result.UnmarshalCBOR(b); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if !bytes.Equal(result.MarshalBytes(), c.MarshalBytes()) {
+		t.Errorf("expected identical binary representation after CBOR round trip")
+	}
+	b[len(b)-1] = 2
+	if result.GetPassword()[299] != 1 {
+		t.Errorf("expected fields to be copied")
+	}
+
+	empty, _ := New().MarshalCBOR()
+	if !bytes.Equal(empty, []byte{0xa1, 0x00, 0x83, 0, 0, 0}) {
+		t.Errorf("expected minimal encoding of an empty container, got %x", empty)
+	}
+}
+
+func Test_Container_UnmarshalCBOR_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{"empty", nil},
+		{"not a map", []byte{0x80}},
+		{"indefinite map", []byte{0xbf, 0x04, 0x41, 'a', 0xff}},
+		{"indefinite byte string", []byte{0xa1, 0x04, 0x5f, 0x41, 'a', 0xff}},
+		{"non-deterministic length", []byte{0xa1, 0x04, 0x58, 0x01, 'a'}},
+		{"non-deterministic key", []byte{0xa1, 0x18, 0x04, 0x41, 'a'}},
+		{"unsorted keys", []byte{0xa2, 0x05, 0x41, 'a', 0x04, 0x41, 'b'}},
+		{"duplicate keys", []byte{0xa2, 0x04, 0x41, 'a', 0x04, 0x41, 'b'}},
+		{"unknown key", []byte{0xa1, 0x0d, 0x41, 'a'}},
+		{"text instead of bytes", []byte{0xa1, 0x04, 0x61, 'a'}},
+		{"version too short", []byte{0xa1, 0x00, 0x82, 1, 2}},
+		{"version out of range", []byte{0xa1, 0x00, 0x83, 0x19, 0x01, 0x00, 0, 0}},
+		{"too many entries", []byte{0xae}},
+		{"field too large", []byte{0xa1, 0x04, 0x5a, 0x00, 0x01, 0x00, 0x00}},
+		{"truncated", []byte{0xa1, 0x04, 0x42, 'a'}},
+		{"trailing data", []byte{0xa1, 0x04, 0x41, 'a', 0x00}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New().SetEmail([]byte("unchanged"))
+			if err := c.UnmarshalCBOR(tt.input); err == nil {
+				t.Errorf("expected an error, got nil")
+			}
+			if string(c.GetEmail()) != "unchanged" {
+				t.Errorf("expected container to be unchanged on error")
+			}
+		})
+	}
+}
+
+func Test_Container_UnmarshalCBOR_KeepsSettings(t *testing.T) {
+	c := New()
+	if err := c.SetCompression(FieldCertificate, true); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	b, err := c.SetCertificate(bytes.Repeat([]byte("A"), 1000)).MarshalCBOR()
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+
+	var tooLarge *ErrFieldTooLarge
+	if err := New().SetMaxDecompressedSize(100).UnmarshalCBOR(b); !errors.As(err, &tooLarge) || tooLarge.Limit != 100 {
+		t.Errorf("expected *ErrFieldTooLarge with limit 100, got %v", err)
+	}
+
+	copying := New().SetDefensiveCopies(true).SetChecksum(ChecksumCRC32C)
+	if err := copying.UnmarshalCBOR(b); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	copying.GetCertificate()[0] = 'B'
+	if copying.GetCertificate()[0] != 'A' {
+		t.Errorf("expected defensive copies to be kept")
+	}
+	if copying.Checksum() != ChecksumCRC32C {
+		t.Errorf("expected checksum algorithm to be kept, got %v", copying.Checksum())
+	}
+}
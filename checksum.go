@@ -0,0 +1,217 @@
+package eraf
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strings"
+)
+
+// Checksum identifies the algorithm of the optional integrity checksum trailer
+type Checksum uint8
+
+// All supported checksum algorithms
+const (
+	ChecksumNone Checksum = iota
+	ChecksumCRC32C
+	ChecksumSHA256
+)
+
+// The checksum trailer follows the payload, including the extension area. It is only written if a checksum
+// algorithm has been set using SetChecksum, which is recorded in the meta data of the version block.
+//
+//	per region, 4 bytes: CRC32-C of the header, the version block, each field in wire order and the extension area
+//	4 bytes CRC32-C or 32 bytes SHA-256 over everything in front of it, including the region checksums
+//
+// The region checksums tell which part of a corrupted container is affected.
+const (
+	regionCount     = 2 + int(fieldCount) + 1
+	regionSumSize   = 4
+	regionSumsSize  = regionCount * regionSumSize
+	regionHeader    = 0
+	regionVersion   = 1
+	regionExtension = regionCount - 1
+)
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrChecksumMismatch is wrapped by *ChecksumError, so it can be checked using errors.Is
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ChecksumError is returned by Unmarshal if the checksum trailer does not match the data. It reports which
+// regions of the container are affected.
+type ChecksumError struct {
+	Header     bool
+	Version    bool
+	Fields     []FieldID
+	Extensions bool
+	// Trailer is set if the data is intact, but the trailer itself is corrupted
+	Trailer bool
+}
+
+func (e *ChecksumError) Error() string {
+	var regions []string
+	if e.Header {
+		regions = append(regions, "header")
+	}
+	if e.Version {
+		regions = append(regions, "version")
+	}
+	for _, id := range e.Fields {
+		regions = append(regions, id.String())
+	}
+	if e.Extensions {
+		regions = append(regions, "extensions")
+	}
+	if e.Trailer {
+		regions = append(regions, "trailer")
+	}
+	return fmt.Sprintf("%s in %s", ErrChecksumMismatch, strings.Join(regions, ", "))
+}
+
+func (e *ChecksumError) Unwrap() error {
+	return ErrChecksumMismatch
+}
+
+// String returns the name of the checksum algorithm, e.g. CRC32-C
+func (ch Checksum) String() string {
+	switch ch {
+	case ChecksumNone:
+		return "none"
+	case ChecksumCRC32C:
+		return "CRC32-C"
+	case ChecksumSHA256:
+		return "SHA-256"
+	}
+	return fmt.Sprintf("Checksum(%d)", uint8(ch))
+}
+
+func (ch Checksum) digestSize() int {
+	switch ch {
+	case ChecksumCRC32C:
+		return 4
+	case ChecksumSHA256:
+		return sha256.Size
+	}
+	return 0
+}
+
+// trailerLen returns the length of the checksum trailer
+func (ch Checksum) trailerLen() int {
+	if ch == ChecksumNone {
+		return 0
+	}
+	return regionSumsSize + ch.digestSize()
+}
+
+// appendDigest appends the digest of b to dst without allocating, as long as dst has enough capacity
+func (ch Checksum) appendDigest(dst, b []byte) []byte {
+	switch ch {
+	case ChecksumCRC32C:
+		return binary.BigEndian.AppendUint32(dst, crc32.Checksum(b, crc32c))
+	case ChecksumSHA256:
+		sum := sha256.Sum256(b)
+		return append(dst, sum[:]...)
+	}
+	return dst
+}
+
+// matches reports whether sum is the digest of b without allocating
+func (ch Checksum) matches(b, sum []byte) bool {
+	switch ch {
+	case ChecksumCRC32C:
+		return crc32.Checksum(b, crc32c) == binary.BigEndian.Uint32(sum)
+	case ChecksumSHA256:
+		digest := sha256.Sum256(b)
+		return bytes.Equal(digest[:], sum)
+	}
+	return false
+}
+
+// SetChecksum sets the algorithm of the checksum trailer written when marshalling. ChecksumNone, the default,
+// omits the trailer.
+func (c *Container) SetChecksum(ch Checksum) *Container {
+	c.checksum = ch
+	return c
+}
+
+// Checksum returns the algorithm of the checksum trailer, e.g. as read by Unmarshal
+func (c *Container) Checksum() Checksum {
+	return c.checksum
+}
+
+// SetLenient enables or disables lenient mode for unmarshalling into the container. In lenient mode, a container
+// whose checksum does not match is loaded nonetheless and the *ChecksumError is returned afterwards.
+func (c *Container) SetLenient(lenient bool) *Container {
+	c.lenient = lenient
+	return c
+}
+
+// regions returns the regions covered by the region checksums of a marshalled container without trailer
+func regions(b []byte) [regionCount][]byte {
+	var (
+		r       [regionCount][]byte
+		headers = b[:headerSize]
+		payload = b[headerSize:]
+	)
+	r[regionHeader] = headers
+	r[regionVersion] = payload[headers[0] : int(headers[0])+int(headers[1])]
+	for id := 0; id < int(fieldCount); id++ {
+		at := 2 + 4*id
+		position := int(binary.BigEndian.Uint16(headers[at : at+2]))
+		r[2+id] = payload[position : position+int(binary.BigEndian.Uint16(headers[at+2:at+4]))]
+	}
+	r[regionExtension] = payload[fieldsEnd(headers):]
+	return r
+}
+
+// appendTrailer appends the checksum trailer for the marshalled container starting at b[start:]
+func appendTrailer(b []byte, start int, ch Checksum) []byte {
+	if ch == ChecksumNone {
+		return b
+	}
+	for _, r := range regions(b[start:]) {
+		b = binary.BigEndian.AppendUint32(b, crc32.Checksum(r, crc32c))
+	}
+	return ch.appendDigest(b, b[start:])
+}
+
+// verifyChecksum verifies the checksum trailer at the end of b
+func verifyChecksum(b []byte, ch Checksum) *ChecksumError {
+	var (
+		covered = b[:len(b)-ch.digestSize()]
+		n       = len(covered) - regionSumsSize
+	)
+	if ch.matches(covered, b[len(covered):]) {
+		return nil
+	}
+
+	e := &ChecksumError{}
+	if checkBounds(b[:headerSize], n-int(headerSize)) != nil {
+		e.Header = true
+		return e
+	}
+	sums := b[n:len(covered)]
+	for i, r := range regions(b[:n]) {
+		if crc32.Checksum(r, crc32c) == binary.BigEndian.Uint32(sums[i*regionSumSize:]) {
+			continue
+		}
+		switch i {
+		case regionHeader:
+			e.Header = true
+		case regionVersion:
+			e.Version = true
+		case regionExtension:
+			e.Extensions = true
+		default:
+			e.Fields = append(e.Fields, FieldID(i-2))
+		}
+	}
+	if !e.Header && !e.Version && len(e.Fields) == 0 && !e.Extensions {
+		e.Trailer = true
+	}
+	return e
+}
@@ -0,0 +1,109 @@
+package eraf
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func Test_Container_Checksum(t *testing.T) {
+	for _, ch := range []Checksum{ChecksumCRC32C, ChecksumSHA256} {
+		t.Run(ch.String(), func(t *testing.T) {
+			c := New().SetChecksum(ch).SetEmail([]byte("someone@example.com")).SetToken([]byte("token"))
+			b := c.MarshalBytes()
+			if len(b) != c.Len() {
+				t.Errorf("expected %d bytes, got %d", c.Len(), len(b))
+			}
+
+			result := &Container{}
+			if err := UnmarshalBytes(b, result); err != nil {
+				t.Fatalf("expected no error, got %s", err.Error())
+			}
+			if result.Checksum() != ch || !bytes.Equal(result.MarshalBytes(), b) {
+				t.Errorf("expected checksum to survive the round trip")
+			}
+
+			var buf bytes.Buffer
+			if err := c.Marshal(&buf); err != nil {
+				t.Fatalf("expected no error, got %s", err.Error())
+			}
+			if err := Unmarshal(&buf, result); err != nil {
+				t.Errorf("expected no error, got %s", err.Error())
+			}
+		})
+	}
+}
+
+func Test_Container_Checksum_Mismatch(t *testing.T) {
+	c := New().SetChecksum(ChecksumSHA256).SetEmail([]byte("someone@example.com")).SetToken([]byte("token"))
+	_ = c.SetExtension(Extension{Key: StringKey("tenant"), Value: []byte("acme")})
+	b := c.MarshalBytes()
+	emailAt := int(headerSize) + int(c.Headers()[2+4*int(FieldEmail)+1])
+
+	tests := []struct {
+		name     string
+		at       int
+		expected ChecksumError
+	}{
+		{"email", emailAt, ChecksumError{Fields: []FieldID{FieldEmail}}},
+		{"version", int(headerSize) + 1, ChecksumError{Version: true}},
+		{"header", 3, ChecksumError{Header: true}},
+		{"extensions", len(b) - ChecksumSHA256.trailerLen() - 1, ChecksumError{Extensions: true}},
+		{"trailer", len(b) - 1, ChecksumError{Trailer: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			corrupted := append([]byte(nil), b...)
+			corrupted[tt.at] ^= 0x01
+
+			result := New().SetEmail([]byte("unchanged"))
+			err := UnmarshalBytes(corrupted, result)
+			if !errors.Is(err, ErrChecksumMismatch) {
+				t.Fatalf("expected ErrChecksumMismatch, got %v", err)
+			}
+			var checksumErr *ChecksumError
+			if !errors.As(err, &checksumErr) || checksumErr.Error() != tt.expected.Error() {
+				t.Errorf("expected '%s', got '%v'", tt.expected.Error(), err)
+			}
+			if string(result.GetEmail()) != "unchanged" {
+				t.Errorf("expected container to be unchanged")
+			}
+		})
+	}
+
+	corrupted := append([]byte(nil), b...)
+	corrupted[emailAt] = 'S'
+	result := New().SetLenient(true)
+	err := UnmarshalBytes(corrupted, result)
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("expected ErrChecksumMismatch in lenient mode, got %v", err)
+	}
+	if string(result.GetEmail()) != "Someone@example.com" || string(result.GetToken()) != "token" {
+		t.Errorf("expected data to be loaded in lenient mode, got email '%s'", result.GetEmail())
+	}
+
+	if err = UnmarshalBytes(b[:int(headerSize)+versionSize+presenceSize+flagsSize+checksumSize], &Container{}); err == nil {
+		t.Errorf("expected error for truncated trailer")
+	}
+}
+
+func Test_Container_Checksum_Stripped(t *testing.T) {
+	for _, withExtension := range []bool{false, true} {
+		c := New().SetChecksum(ChecksumCRC32C).SetEmail([]byte("a@b.c"))
+		if withExtension {
+			_ = c.SetExtension(Extension{Key: StringKey("tenant"), Value: []byte("acme")})
+		}
+		b := c.MarshalBytes()
+
+		// clear the checksum algorithm and corrupt the email
+		b[int(headerSize)+versionSize+presenceSize+flagsSize] ^= byte(ChecksumCRC32C)
+		b[int(headerSize)+int(c.Headers()[2+4*int(FieldEmail)+1])] = '`'
+
+		if err := UnmarshalBytes(b, New()); err == nil {
+			t.Errorf("expected error for stripped checksum, extension: %t", withExtension)
+		}
+		if _, err := NewView(b); err == nil {
+			t.Errorf("expected error from NewView for stripped checksum, extension: %t", withExtension)
+		}
+	}
+}
@@ -0,0 +1,424 @@
+package main
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	eraf "github.com/KaiserWerk/ERAF-Go-SDK"
+)
+
+func runCreate(e *env, args []string) error {
+	var (
+		fs          = newFlagSet(e, "create", "[flags]")
+		out         = fs.String("o", "-", "write the container to `file`")
+		format      = fs.String("format", formatBinary, "output `format`: binary, base64, pem or json")
+		version     = fs.String("version", "0.0.0", "container `version` as major.minor.patch")
+		randomNonce = fs.Bool("random-nonce", false, "generate a random 12 byte nonce")
+		values      = make(map[string][]byte)
+	)
+	for _, f := range fields {
+		name := f.name
+		fs.Func(name, "set "+name+" to `text`", func(s string) error {
+			values[name] = []byte(s)
+			return nil
+		})
+		fs.Func(name+"-hex", "set "+name+" to hex encoded `bytes`", func(s string) error {
+			b, err := hex.DecodeString(s)
+			values[name] = b
+			return err
+		})
+		fs.Func(name+"-file", "read "+name+" from `file`", func(s string) error {
+			b, err := readInput(e, s)
+			values[name] = b
+			return err
+		})
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errUsage("unexpected arguments")
+	}
+
+	c := eraf.New()
+	if err := c.SetSemVer(*version); err != nil {
+		return errUsage(err.Error())
+	}
+	for _, f := range fields {
+		if v, ok := values[f.name]; ok {
+			if err := c.Set(f.id, v); err != nil {
+				return errUsage(err.Error())
+			}
+		}
+	}
+	if *randomNonce {
+		if err := c.SetRandomNonce(); err != nil {
+			return err
+		}
+	}
+
+	b, err := encode(c, *format)
+	if err != nil {
+		return err
+	}
+	return writeOutput(e, *out, b)
+}
+
+func runInspect(e *env, args []string) error {
+	fs := newFlagSet(e, "inspect", "[flags] [file]")
+	showSecrets := fs.Bool("show-secrets", false, "show private key, token and password instead of redacting them")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	file := fileArg(fs)
+
+	in, err := readInput(e, file)
+	if err != nil {
+		return err
+	}
+	c, format, err := decode(in)
+	if err != nil {
+		return err
+	}
+
+	headers := c.Headers()
+	_, _ = fmt.Fprintf(e.stdout, "File:     %s (%s, %d bytes)\n", file, format, len(in))
+	_, _ = fmt.Fprintf(e.stdout, "Version:  %s\n", c.GetSemVer())
+	_, _ = fmt.Fprintf(e.stdout, "Header:   %d bytes\n", c.HeaderLen())
+	_, _ = fmt.Fprintf(e.stdout, "Payload:  %d bytes\n", c.PayloadLen())
+	if c.IsEncrypted() {
+		names := make([]string, 0, len(fields))
+		for _, id := range c.EncryptedFields() {
+			names = append(names, fields[id].name)
+		}
+		_, _ = fmt.Fprintf(e.stdout, "Encrypted: %s (%s, key derivation: %s)\n", strings.Join(names, ", "), c.Cipher(), c.KDF())
+	}
+	if c.IsSigned() {
+		_, _ = fmt.Fprintln(e.stdout, "Signed:   yes")
+	}
+	_, _ = fmt.Fprintln(e.stdout)
+
+	tw := tabwriter.NewWriter(e.stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "FIELD\tPOSITION\tLENGTH\tVALUE")
+	_, _ = fmt.Fprintf(tw, "version\t%d\t%d\t%s\n", headers[0], headers[1], c.GetSemVer())
+	compressed := make(map[eraf.FieldID]bool)
+	for _, id := range c.CompressedFields() {
+		compressed[id] = true
+	}
+	for i, f := range fields {
+		at := 2 + 4*i
+		position := int(headers[at])<<8 | int(headers[at+1])
+		stored := int(headers[at+2])<<8 | int(headers[at+3])
+		length := strconv.Itoa(stored)
+		value := c.Get(f.id)
+		if compressed[f.id] && len(value) != stored {
+			// the header holds the stored, i.e. compressed length
+			length += fmt.Sprintf(" (%d uncompressed)", len(value))
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", f.name, position, length, preview(value, f.id.IsSecret() && !*showSecrets))
+	}
+	for _, x := range c.Extensions() {
+		_, _ = fmt.Fprintf(tw, "extension %s\t-\t%d\t%s\n", x.Key, len(x.Value), preview(x.Value, false))
+	}
+	return tw.Flush()
+}
+
+func runGet(e *env, args []string) error {
+	fs := newFlagSet(e, "get", "[flags] <file> <field>")
+	hexOut := fs.Bool("hex", false, "print the field hex encoded")
+	base64Out := fs.Bool("base64", false, "print the field base64 encoded")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errUsage("expected a file and a field name")
+	}
+
+	c, _, err := decodeFile(e, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var value []byte
+	if fs.Arg(1) == "version" {
+		value = []byte(c.GetSemVer())
+	} else {
+		f, ok := fieldByName(fs.Arg(1))
+		if !ok {
+			return errUsage(fmt.Sprintf("unknown field '%s'", fs.Arg(1)))
+		}
+		value = c.Get(f.id)
+	}
+
+	switch {
+	case *hexOut:
+		value = []byte(hex.EncodeToString(value) + "\n")
+	case *base64Out:
+		value = []byte(base64.StdEncoding.EncodeToString(value) + "\n")
+	}
+	_, err = e.stdout.Write(value)
+	return err
+}
+
+func runSet(e *env, args []string) error {
+	fs := newFlagSet(e, "set", "[flags] <file> <field> [value]")
+	out := fs.String("o", "", "write the result to `file` instead of changing the input file")
+	hexIn := fs.Bool("hex", false, "the value is hex encoded")
+	valueFile := fs.String("file", "", "read the value from `file` instead of the command line")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 || fs.NArg() > 3 || (fs.NArg() == 3) == (*valueFile != "") {
+		return errUsage("expected a file, a field name and either a value or -file")
+	}
+
+	c, format, err := decodeFile(e, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var value []byte
+	if *valueFile != "" {
+		if value, err = readInput(e, *valueFile); err != nil {
+			return err
+		}
+	} else {
+		value = []byte(fs.Arg(2))
+	}
+	if *hexIn {
+		if value, err = hex.DecodeString(strings.TrimSpace(string(value))); err != nil {
+			return errUsage(err.Error())
+		}
+	}
+
+	if fs.Arg(1) == "version" {
+		if err = c.SetSemVer(string(value)); err != nil {
+			return errUsage(err.Error())
+		}
+	} else {
+		f, ok := fieldByName(fs.Arg(1))
+		if !ok {
+			return errUsage(fmt.Sprintf("unknown field '%s'", fs.Arg(1)))
+		}
+		if err = c.Set(f.id, value); err != nil {
+			return errUsage(err.Error())
+		}
+	}
+
+	return encodeFile(e, c, format, outputFile(fs.Arg(0), *out))
+}
+
+func runEncrypt(e *env, args []string) error {
+	return runCrypt(e, "encrypt", args, func(c *eraf.Container, ko *keyOptions) error {
+		if err := c.SetRandomNonce(); err != nil {
+			return err
+		}
+		key, err := ko.key(c, e)
+		if err != nil {
+			return err
+		}
+		if err = c.EncryptEverything(c.GetNonce(), key); err != nil {
+			return err
+		}
+		c.SetKDF(ko.kdf())
+		return nil
+	})
+}
+
+func runDecrypt(e *env, args []string) error {
+	return runCrypt(e, "decrypt", args, func(c *eraf.Container, ko *keyOptions) error {
+		key, err := ko.key(c, e)
+		if err != nil {
+			return err
+		}
+		return c.DecryptEverything(c.GetNonce(), key)
+	})
+}
+
+func runCrypt(e *env, name string, args []string, fn func(c *eraf.Container, ko *keyOptions) error) error {
+	var (
+		fs  = newFlagSet(e, name, "[flags] [file]")
+		out = fs.String("o", "", "write the result to `file` instead of changing the input file")
+		ko  = &keyOptions{}
+	)
+	ko.register(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	file := fileArg(fs)
+
+	c, format, err := decodeFile(e, file)
+	if err != nil {
+		return err
+	}
+	if err = fn(c, ko); err != nil {
+		return err
+	}
+	return encodeFile(e, c, format, outputFile(file, *out))
+}
+
+func runVerify(e *env, args []string) error {
+	fs := newFlagSet(e, "verify", "[flags] [file]")
+	rootFile := fs.String("root", "", "verify against the root certificate in `file` instead of the one in the container")
+	at := fs.String("time", "", "verify the validity period at the given RFC 3339 `time` instead of now")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if *at != "" {
+		t, err := time.Parse(time.RFC3339, *at)
+		if err != nil {
+			return errUsage(err.Error())
+		}
+		now = t
+	}
+
+	c, _, err := decodeFile(e, fileArg(fs))
+	if err != nil {
+		return err
+	}
+
+	cert, err := c.GetX509Certificate()
+	if err != nil {
+		return errVerify("certificate: " + err.Error())
+	}
+	_, _ = fmt.Fprintf(e.stdout, "certificate: ok (%s)\n", cert.Subject)
+
+	if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return errVerify(fmt.Sprintf("certificate is only valid from %s to %s", cert.NotBefore, cert.NotAfter))
+	}
+	_, _ = fmt.Fprintf(e.stdout, "validity: ok (until %s)\n", cert.NotAfter.Format(time.RFC3339))
+
+	if len(c.GetPrivateKey()) > 0 {
+		if _, err = c.GetTlsCertificate(); err != nil {
+			return errVerify("private key: " + err.Error())
+		}
+		_, _ = fmt.Fprintln(e.stdout, "private key: ok (matches certificate)")
+	}
+
+	rootPEM := c.GetRootCertificate()
+	if *rootFile != "" {
+		if rootPEM, err = ioutil.ReadFile(*rootFile); err != nil {
+			return err
+		}
+	}
+	if len(rootPEM) == 0 {
+		_, _ = fmt.Fprintln(e.stdout, "chain: skipped (no root certificate)")
+		return nil
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(rootPEM) {
+		return errVerify("root certificate: no PEM encoded certificate found")
+	}
+	if _, err = cert.Verify(x509.VerifyOptions{
+		Roots:       roots,
+		CurrentTime: now,
+		KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return errVerify("chain: " + err.Error())
+	}
+	_, _ = fmt.Fprintln(e.stdout, "chain: ok")
+
+	return nil
+}
+
+func runConvert(e *env, args []string) error {
+	fs := newFlagSet(e, "convert", "-to <format> [flags] [file]")
+	to := fs.String("to", "", "output `format`: binary, base64, pem or json")
+	out := fs.String("o", "-", "write the result to `file`")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *to == "" {
+		return errUsage("-to is required")
+	}
+
+	c, _, err := decodeFile(e, fileArg(fs))
+	if err != nil {
+		return err
+	}
+	b, err := encode(c, *to)
+	if err != nil {
+		return err
+	}
+	return writeOutput(e, *out, b)
+}
+
+// fileArg returns the only positional argument, defaulting to stdin
+func fileArg(fs interface{ Arg(int) string }) string {
+	if f := fs.Arg(0); f != "" {
+		return f
+	}
+	return "-"
+}
+
+// outputFile returns the explicitly given output file or the input file for in-place changes
+func outputFile(in, out string) string {
+	if out != "" {
+		return out
+	}
+	return in
+}
+
+func decodeFile(e *env, file string) (*eraf.Container, string, error) {
+	b, err := readInput(e, file)
+	if err != nil {
+		return nil, "", err
+	}
+	return decode(b)
+}
+
+func encodeFile(e *env, c *eraf.Container, format, file string) error {
+	b, err := encode(c, format)
+	if err != nil {
+		return err
+	}
+	return writeOutput(e, file, b)
+}
+
+// preview returns a short, printable representation of a field value
+func preview(b []byte, redact bool) string {
+	const maxLen = 48
+
+	switch {
+	case len(b) == 0:
+		return "-"
+	case redact:
+		return "<redacted>"
+	case bytes.HasPrefix(b, []byte("-----BEGIN ")):
+		line := b
+		if i := bytes.IndexByte(b, '\n'); i >= 0 {
+			line = b[:i]
+		}
+		return string(bytes.TrimSpace(line)) + " ..."
+	case utf8.Valid(b) && isPrintable(string(b)):
+		if len(b) > maxLen {
+			return fmt.Sprintf("%q ...", b[:maxLen])
+		}
+		return fmt.Sprintf("%q", b)
+	default:
+		if len(b) > maxLen/2 {
+			return hex.EncodeToString(b[:maxLen/2]) + " ..."
+		}
+		return hex.EncodeToString(b)
+	}
+}
+
+func isPrintable(s string) bool {
+	for _, r := range s {
+		if r < 0x20 || r == 0x7f {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd
+
+package main
+
+import "errors"
+
+// disableEcho is not supported on this platform, so the passphrase is echoed
+func disableEcho(fd uintptr) (func(), error) {
+	return nil, errors.New("not supported")
+}
@@ -0,0 +1,26 @@
+//go:build linux || darwin || freebsd
+
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// disableEcho turns off the terminal echo of fd and returns a function restoring the previous state
+func disableEcho(fd uintptr) (func(), error) {
+	var state syscall.Termios
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, ioctlGetTermios, uintptr(unsafe.Pointer(&state))); errno != 0 {
+		return nil, errno
+	}
+
+	noEcho := state
+	noEcho.Lflag &^= syscall.ECHO
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, ioctlSetTermios, uintptr(unsafe.Pointer(&noEcho))); errno != 0 {
+		return nil, errno
+	}
+
+	return func() {
+		_, _, _ = syscall.Syscall(syscall.SYS_IOCTL, fd, ioctlSetTermios, uintptr(unsafe.Pointer(&state)))
+	}, nil
+}
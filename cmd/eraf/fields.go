@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	eraf "github.com/KaiserWerk/ERAF-Go-SDK"
+)
+
+// field describes a data block of a container as it is addressed on the command line
+type field struct {
+	name string
+	id   eraf.FieldID
+}
+
+// fields lists all data blocks in the order they are stored in the file. The names are the field names of
+// the SDK in kebab case, e.g. serial-number.
+var fields = func() []field {
+	all := eraf.New().Fields()
+	fields := make([]field, len(all))
+	for i, f := range all {
+		fields[i] = field{name: kebabCase(f.Name), id: f.ID}
+	}
+	return fields
+}()
+
+func fieldByName(name string) (field, bool) {
+	id, err := eraf.ParseFieldID(camelCase(name))
+	if err != nil || kebabCase(id.String()) != name {
+		return field{}, false
+	}
+	return field{name: name, id: id}, true
+}
+
+// kebabCase turns a field name like serialNumber into serial-number
+func kebabCase(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if unicode.IsUpper(r) {
+			b.WriteByte('-')
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// camelCase turns a command line name like serial-number into serialNumber
+func camelCase(s string) string {
+	parts := strings.Split(s, "-")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	eraf "github.com/KaiserWerk/ERAF-Go-SDK"
+)
+
+const (
+	formatBinary = "binary"
+	formatBase64 = "base64"
+	formatPEM    = "pem"
+	formatJSON   = "json"
+
+	pemType = "ERAF CONTAINER"
+)
+
+// encode serializes the container in the given format
+func encode(c *eraf.Container, format string) ([]byte, error) {
+	switch format {
+	case formatBinary:
+		return c.MarshalBinary()
+	case formatBase64:
+		b, err := c.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		return []byte(base64.StdEncoding.EncodeToString(b) + "\n"), nil
+	case formatPEM:
+		return c.MarshalArmored(nil)
+	case formatJSON:
+		b, err := json.MarshalIndent(c, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(b, '\n'), nil
+	default:
+		return nil, errUsage(fmt.Sprintf("unknown format '%s'", format))
+	}
+}
+
+// decode deserializes a container, detecting the format of the input
+func decode(b []byte) (*eraf.Container, string, error) {
+	format := detectFormat(b)
+	c := eraf.New()
+
+	switch format {
+	case formatBinary:
+		return c, format, eraf.UnmarshalBytes(b, c)
+	case formatBase64:
+		raw, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(b)))
+		if err != nil {
+			return nil, format, err
+		}
+		return c, format, eraf.UnmarshalBytes(raw, c)
+	case formatPEM:
+		_, err := eraf.UnmarshalArmored(b, c)
+		return c, format, err
+	default:
+		return c, format, json.Unmarshal(b, c)
+	}
+}
+
+func detectFormat(b []byte) string {
+	// a binary container always starts with the position (0) and length (at least 3) of the version block
+	if len(b) >= 2 && b[0] == 0 && b[1] >= 3 {
+		return formatBinary
+	}
+	trimmed := bytes.TrimSpace(b)
+	switch {
+	case bytes.Contains(trimmed, []byte("-----BEGIN "+pemType+"-----")):
+		return formatPEM
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		return formatJSON
+	default:
+		return formatBase64
+	}
+}
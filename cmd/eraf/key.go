@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	eraf "github.com/KaiserWerk/ERAF-Go-SDK"
+)
+
+// passphraseIterations is the PBKDF2 work factor used for keys derived from a passphrase. The nonce of
+// the container serves as salt, so a new key is derived every time a container is encrypted.
+const passphraseIterations = 600000
+
+// ttyPath is the terminal the passphrase is read from. If it cannot be opened, the passphrase is read from
+// the standard input instead.
+var ttyPath = "/dev/tty"
+
+// keyOptions are the flags for obtaining an AES key
+type keyOptions struct {
+	file       string
+	env        string
+	passphrase bool
+}
+
+func (o *keyOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.file, "key-file", "", "read the AES key (16, 24 or 32 bytes, raw or hex) from `file`")
+	fs.StringVar(&o.env, "key-env", "", "read the AES key (raw or hex) from environment `variable`")
+	fs.BoolVar(&o.passphrase, "passphrase", false, "derive the AES key from a passphrase entered on the terminal")
+}
+
+// key returns the AES key for the container, whose nonce has to be set already
+func (o *keyOptions) key(c *eraf.Container, e *env) ([]byte, error) {
+	switch {
+	case o.file != "":
+		b, err := ioutil.ReadFile(o.file)
+		if err != nil {
+			return nil, err
+		}
+		return parseKey(b)
+	case o.env != "":
+		v, ok := os.LookupEnv(o.env)
+		if !ok {
+			return nil, fmt.Errorf("environment variable %s is not set", o.env)
+		}
+		return parseKey([]byte(v))
+	case o.passphrase:
+		pass, err := readPassphrase(e.stdin, e.stderr)
+		if err != nil {
+			return nil, err
+		}
+		return eraf.DeriveKey(pass, c.GetNonce(), passphraseIterations), nil
+	default:
+		return nil, errUsage("one of -key-file, -key-env or -passphrase is required")
+	}
+}
+
+// kdf returns the key derivation function used by key, to be recorded in encrypted containers
+func (o *keyOptions) kdf() eraf.KDF {
+	if o.file == "" && o.env == "" && o.passphrase {
+		return eraf.KDFPBKDF2SHA256
+	}
+	return eraf.KDFNone
+}
+
+// parseKey accepts a hex encoded or raw AES key
+func parseKey(b []byte) ([]byte, error) {
+	trimmed := bytes.TrimSpace(b)
+	if k, err := hex.DecodeString(string(trimmed)); err == nil && validKeyLen(len(k)) {
+		return k, nil
+	}
+	if validKeyLen(len(b)) {
+		return b, nil
+	}
+	if validKeyLen(len(trimmed)) {
+		return trimmed, nil
+	}
+	return nil, fmt.Errorf("key must be 16, 24 or 32 bytes long")
+}
+
+func validKeyLen(n int) bool {
+	return n == 16 || n == 24 || n == 32
+}
+
+// readPassphrase prompts for a passphrase on the terminal, falling back to stdin if there is none
+func readPassphrase(stdin io.Reader, prompt io.Writer) ([]byte, error) {
+	in := stdin
+	_, _ = fmt.Fprint(prompt, "Passphrase: ")
+	if tty, err := os.Open(ttyPath); err == nil {
+		defer func() {
+			_ = tty.Close()
+		}()
+		in = tty
+		if restore, err := disableEcho(tty.Fd()); err == nil {
+			defer func() {
+				restore()
+				_, _ = fmt.Fprintln(prompt)
+			}()
+		}
+	}
+
+	line, err := bufio.NewReader(in).ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	line = bytes.TrimRight(line, "\r\n")
+	if len(line) == 0 {
+		return nil, fmt.Errorf("empty passphrase")
+	}
+	return line, nil
+}
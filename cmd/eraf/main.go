@@ -0,0 +1,192 @@
+// Command eraf creates, inspects, edits, encrypts and converts ERAF files.
+//
+// Usage:
+//
+//	eraf <command> [flags] [arguments]
+//
+// Run eraf help for the list of commands. Wherever a file is expected, - means stdin or stdout.
+//
+// Exit codes:
+//
+//	0  success
+//	1  error, e.g. a file could not be read, parsed or decrypted
+//	2  invalid usage
+//	3  verification failed
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const (
+	exitOK     = 0
+	exitError  = 1
+	exitUsage  = 2
+	exitVerify = 3
+)
+
+// errUsage is returned for invalid command line usage
+type errUsage string
+
+func (e errUsage) Error() string {
+	return string(e)
+}
+
+// errVerify is returned if a container failed verification
+type errVerify string
+
+func (e errVerify) Error() string {
+	return string(e)
+}
+
+// env holds the standard streams, so commands can be tested
+type env struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+type command struct {
+	summary string
+	run     func(e *env, args []string) error
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"create":  {"create a new container from flags, files or stdin", runCreate},
+		"inspect": {"show version, headers and field sizes (secrets are redacted)", runInspect},
+		"get":     {"print a single field", runGet},
+		"set":     {"change a single field", runSet},
+		"encrypt": {"encrypt all fields using a random nonce", runEncrypt},
+		"decrypt": {"decrypt all fields", runDecrypt},
+		"verify":  {"verify certificate, private key and certificate chain", runVerify},
+		"convert": {"convert between binary, base64, pem and json", runConvert},
+	}
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	e := &env{stdin: stdin, stdout: stdout, stderr: stderr}
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printUsage(stderr)
+		if len(args) == 0 {
+			return exitUsage
+		}
+		return exitOK
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		_, _ = fmt.Fprintf(stderr, "eraf: unknown command '%s'\n", args[0])
+		printUsage(stderr)
+		return exitUsage
+	}
+
+	err := cmd.run(e, args[1:])
+	var (
+		usageErr  errUsage
+		verifyErr errVerify
+	)
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, flag.ErrHelp):
+		return exitOK
+	case errors.As(err, &usageErr):
+		_, _ = fmt.Fprintf(stderr, "eraf %s: %s\n", args[0], err.Error())
+		return exitUsage
+	case errors.As(err, &verifyErr):
+		_, _ = fmt.Fprintf(stderr, "eraf %s: %s\n", args[0], err.Error())
+		return exitVerify
+	default:
+		_, _ = fmt.Fprintf(stderr, "eraf %s: %s\n", args[0], err.Error())
+		return exitError
+	}
+}
+
+func printUsage(w io.Writer) {
+	_, _ = fmt.Fprintln(w, "Usage: eraf <command> [flags] [arguments]")
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w, "Commands:")
+	for _, name := range []string{"create", "inspect", "get", "set", "encrypt", "decrypt", "verify", "convert"} {
+		_, _ = fmt.Fprintf(w, "  %-8s %s\n", name, commands[name].summary)
+	}
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w, "Run eraf <command> -h for the flags of a command.")
+	_, _ = fmt.Fprintln(w, "Exit codes: 0 success, 1 error, 2 invalid usage, 3 verification failed")
+}
+
+// newFlagSet creates a flag set which reports errors instead of exiting
+func newFlagSet(e *env, name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(e.stderr, "Usage: eraf %s %s\n", name, usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses the arguments and wraps parse errors as usage errors
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage(err.Error())
+	}
+	return nil
+}
+
+func readInput(e *env, file string) ([]byte, error) {
+	if file == "-" {
+		return ioutil.ReadAll(e.stdin)
+	}
+	return ioutil.ReadFile(file)
+}
+
+// writeOutput writes b to stdout or into the file, which is replaced atomically, so an interrupted write cannot
+// corrupt it. The permissions of an existing file are kept.
+func writeOutput(e *env, file string, b []byte) error {
+	if file == "-" {
+		_, err := e.stdout.Write(b)
+		return err
+	}
+
+	perms := os.FileMode(0600)
+	if fi, err := os.Stat(file); err == nil {
+		perms = fi.Mode().Perm()
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(file), ".eraf-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(b)
+	if err == nil {
+		err = tmp.Chmod(perms)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), file)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
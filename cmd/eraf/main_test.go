@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	eraf "github.com/KaiserWerk/ERAF-Go-SDK"
+)
+
+func runCmd(t *testing.T, stdin string, args ...string) (string, int) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	if code != exitOK {
+		t.Logf("eraf %s: %s", strings.Join(args, " "), stderr.String())
+	}
+	return stdout.String(), code
+}
+
+func Test_CreateInspectGetSet(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "test.eraf")
+
+	if _, code := runCmd(t, "my-secret-token", "create", "-o", file, "-version", "1.2.3",
+		"-email", "someone@example.com", "-serial-number-hex", "0a0b", "-token-file", "-"); code != exitOK {
+		t.Fatalf("expected exit code %d, got %d", exitOK, code)
+	}
+
+	out, code := runCmd(t, "", "inspect", file)
+	if code != exitOK {
+		t.Fatalf("expected exit code %d, got %d", exitOK, code)
+	}
+	if !strings.Contains(out, "1.2.3") || !strings.Contains(out, "someone@example.com") {
+		t.Errorf("expected version and email in output, got:\n%s", out)
+	}
+	if strings.Contains(out, "my-secret-token") || !strings.Contains(out, "<redacted>") {
+		t.Errorf("expected token to be redacted, got:\n%s", out)
+	}
+
+	if out, _ = runCmd(t, "", "get", "-hex", file, "serial-number"); out != "0a0b\n" {
+		t.Errorf("expected serial number '0a0b', got '%s'", out)
+	}
+
+	if _, code = runCmd(t, "", "set", file, "username", "someone"); code != exitOK {
+		t.Fatalf("expected exit code %d, got %d", exitOK, code)
+	}
+	if out, _ = runCmd(t, "", "get", file, "username"); out != "someone" {
+		t.Errorf("expected username 'someone', got '%s'", out)
+	}
+
+	if _, code = runCmd(t, "", "get", file, "unknown-field"); code != exitUsage {
+		t.Errorf("expected exit code %d for unknown field, got %d", exitUsage, code)
+	}
+	if _, code = runCmd(t, "", "get", filepath.Join(t.TempDir(), "missing.eraf"), "email"); code != exitError {
+		t.Errorf("expected exit code %d for missing file, got %d", exitError, code)
+	}
+}
+
+func Test_Inspect_Compressed(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "test.eraf")
+	c := eraf.New().SetUsername(bytes.Repeat([]byte("a"), 1000))
+	if err := c.SetCompression(eraf.FieldUsername, true); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if err := c.MarshalToFile(file, 0600); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+
+	out, code := runCmd(t, "", "inspect", file)
+	if code != exitOK {
+		t.Fatalf("expected exit code %d, got %d", exitOK, code)
+	}
+	if strings.Contains(out, " 1000 ") || !strings.Contains(out, "(1000 uncompressed)") {
+		t.Errorf("expected stored and uncompressed length, got:\n%s", out)
+	}
+}
+
+func Test_FieldNames(t *testing.T) {
+	for _, f := range eraf.New().Fields() {
+		byName, ok := fieldByName(fields[f.ID].name)
+		if !ok || byName.id != f.ID {
+			t.Errorf("expected %s to resolve to %s, got %v", fields[f.ID].name, f.Name, byName.id)
+		}
+	}
+	if fields[eraf.FieldSerialNumber].name != "serial-number" {
+		t.Errorf("expected kebab case names, got %s", fields[eraf.FieldSerialNumber].name)
+	}
+	if _, ok := fieldByName("serialNumber"); ok {
+		t.Errorf("expected camel case name to be rejected")
+	}
+}
+
+func Test_EncryptDecrypt(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "test.eraf")
+	if _, code := runCmd(t, "", "create", "-o", file, "-email", "someone@example.com"); code != exitOK {
+		t.Fatalf("expected exit code %d, got %d", exitOK, code)
+	}
+
+	const keyEnv = "ERAF_TEST_KEY"
+	_ = os.Setenv(keyEnv, "000102030405060708090a0b0c0d0e0f")
+	defer os.Unsetenv(keyEnv)
+
+	if _, code := runCmd(t, "", "encrypt", "-key-env", keyEnv, file); code != exitOK {
+		t.Fatalf("expected exit code %d, got %d", exitOK, code)
+	}
+	if out, _ := runCmd(t, "", "get", file, "email"); out == "someone@example.com" {
+		t.Errorf("expected email to be encrypted")
+	}
+
+	_ = os.Setenv(keyEnv, "ffffffffffffffffffffffffffffffff")
+	if _, code := runCmd(t, "", "decrypt", "-key-env", keyEnv, file); code != exitError {
+		t.Errorf("expected exit code %d for wrong key, got %d", exitError, code)
+	}
+
+	_ = os.Setenv(keyEnv, "000102030405060708090a0b0c0d0e0f")
+	if _, code := runCmd(t, "", "decrypt", "-key-env", keyEnv, file); code != exitOK {
+		t.Fatalf("expected exit code %d, got %d", exitOK, code)
+	}
+	if out, _ := runCmd(t, "", "get", file, "email"); out != "someone@example.com" {
+		t.Errorf("expected decrypted email, got '%s'", out)
+	}
+
+	if _, code := runCmd(t, "", "encrypt", file); code != exitUsage {
+		t.Errorf("expected exit code %d without key, got %d", exitUsage, code)
+	}
+}
+
+func Test_EncryptDecrypt_Passphrase(t *testing.T) {
+	defer func(path string) { ttyPath = path }(ttyPath)
+	ttyPath = filepath.Join(t.TempDir(), "no-tty")
+
+	file := filepath.Join(t.TempDir(), "test.eraf")
+	if _, code := runCmd(t, "", "create", "-o", file, "-email", "someone@example.com"); code != exitOK {
+		t.Fatalf("expected exit code %d, got %d", exitOK, code)
+	}
+
+	if _, code := runCmd(t, "correct horse\n", "encrypt", "-passphrase", file); code != exitOK {
+		t.Fatalf("expected exit code %d, got %d", exitOK, code)
+	}
+	if out, _ := runCmd(t, "", "inspect", file); !strings.Contains(out, "key derivation: pbkdf2-sha256") {
+		t.Errorf("expected key derivation to be recorded, got:\n%s", out)
+	}
+
+	if _, code := runCmd(t, "correct horse\n", "decrypt", "-passphrase", file); code != exitOK {
+		t.Fatalf("expected exit code %d, got %d", exitOK, code)
+	}
+	if out, _ := runCmd(t, "", "get", file, "email"); out != "someone@example.com" {
+		t.Errorf("expected decrypted email, got '%s'", out)
+	}
+}
+
+func Test_Convert(t *testing.T) {
+	original, code := runCmd(t, "", "create", "-version", "4.5.6", "-identifier", "device", "-password", "pass")
+	if code != exitOK {
+		t.Fatalf("expected exit code %d, got %d", exitOK, code)
+	}
+
+	for _, format := range []string{formatBase64, formatPEM, formatJSON} {
+		t.Run(format, func(t *testing.T) {
+			converted, code := runCmd(t, original, "convert", "-to", format)
+			if code != exitOK {
+				t.Fatalf("expected exit code %d, got %d", exitOK, code)
+			}
+			back, code := runCmd(t, converted, "convert", "-to", formatBinary)
+			if code != exitOK {
+				t.Fatalf("expected exit code %d, got %d", exitOK, code)
+			}
+			if back != original {
+				t.Errorf("expected identical container after converting to %s and back", format)
+			}
+		})
+	}
+
+	if _, code = runCmd(t, original, "convert", "-to", "xml"); code != exitUsage {
+		t.Errorf("expected exit code %d for unknown format, got %d", exitUsage, code)
+	}
+}
+
+func Test_Convert_Extensions(t *testing.T) {
+	c := eraf.New().SetIdentifier([]byte("device"))
+	if err := c.SetExtension(eraf.Extension{Key: eraf.StringKey("tenant"), Value: []byte("acme"), Sign: true}); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	original := string(c.MarshalBytes())
+
+	for _, format := range []string{formatBase64, formatPEM, formatJSON} {
+		t.Run(format, func(t *testing.T) {
+			converted, code := runCmd(t, original, "convert", "-to", format)
+			if code != exitOK {
+				t.Fatalf("expected exit code %d, got %d", exitOK, code)
+			}
+			back, code := runCmd(t, converted, "convert", "-to", formatBinary)
+			if code != exitOK {
+				t.Fatalf("expected exit code %d, got %d", exitOK, code)
+			}
+			if back != original {
+				t.Errorf("expected extension to survive converting to %s and back", format)
+			}
+		})
+	}
+}
+
+func Test_Convert_Oversized(t *testing.T) {
+	cert := make([]byte, 70000)
+	_, _ = rand.Read(cert)
+	c := eraf.New()
+	_ = c.SetCompression(eraf.FieldCertificate, true)
+	c.SetCertificate(cert)
+	b, err := json.Marshal(c)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+
+	for _, format := range []string{formatBinary, formatBase64} {
+		if out, code := runCmd(t, string(b), "convert", "-to", format); code != exitError || out != "" {
+			t.Errorf("expected exit code %d and no output for %s, got %d", exitError, format, code)
+		}
+	}
+}
+
+func Test_WriteOutput(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "test.eraf")
+	if _, code := runCmd(t, "", "create", "-o", file, "-email", "someone@example.com"); code != exitOK {
+		t.Fatalf("expected exit code %d, got %d", exitOK, code)
+	}
+	if err := os.Chmod(file, 0640); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if _, code := runCmd(t, "", "set", file, "username", "someone"); code != exitOK {
+		t.Fatalf("expected exit code %d, got %d", exitOK, code)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected only the container file, got %v and error %v", entries, err)
+	}
+	if fi, err := os.Stat(file); err != nil {
+		t.Fatal(err.Error())
+	} else if runtime.GOOS != "windows" && fi.Mode().Perm() != 0640 {
+		t.Errorf("expected permissions to be kept, got %s", fi.Mode().Perm())
+	}
+}
+
+func Test_Verify(t *testing.T) {
+	var (
+		dir       = t.TempDir()
+		file      = filepath.Join(dir, "test.eraf")
+		certFile  = filepath.Join(dir, "cert.pem")
+		keyFile   = filepath.Join(dir, "key.pem")
+		otherFile = filepath.Join(dir, "other.pem")
+	)
+	certPEM, keyPEM := selfSignedCert(t)
+	otherPEM, _ := selfSignedCert(t)
+	for name, b := range map[string][]byte{certFile: certPEM, keyFile: keyPEM, otherFile: otherPEM} {
+		if err := ioutil.WriteFile(name, b, 0600); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	if _, code := runCmd(t, "", "create", "-o", file, "-certificate-file", certFile,
+		"-private-key-file", keyFile, "-root-certificate-file", certFile); code != exitOK {
+		t.Fatalf("expected exit code %d, got %d", exitOK, code)
+	}
+
+	if _, code := runCmd(t, "", "verify", file); code != exitOK {
+		t.Errorf("expected exit code %d, got %d", exitOK, code)
+	}
+	if _, code := runCmd(t, "", "verify", "-root", otherFile, file); code != exitVerify {
+		t.Errorf("expected exit code %d for foreign root, got %d", exitVerify, code)
+	}
+	if _, code := runCmd(t, "", "verify", "-time", "2000-01-01T00:00:00Z", file); code != exitVerify {
+		t.Errorf("expected exit code %d for expired certificate, got %d", exitVerify, code)
+	}
+}
+
+func selfSignedCert(t *testing.T) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "eraf"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
@@ -0,0 +1,197 @@
+package eraf
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"fmt"
+)
+
+// ChangeKind describes how a field differs between two containers
+type ChangeKind uint8
+
+// The kinds of changes reported by Diff
+const (
+	ChangeAdded ChangeKind = iota + 1
+	ChangeRemoved
+	ChangeModified
+)
+
+// String returns the name of the change, e.g. modified
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeAdded:
+		return "added"
+	case ChangeRemoved:
+		return "removed"
+	case ChangeModified:
+		return "modified"
+	}
+	return fmt.Sprintf("ChangeKind(%d)", uint8(k))
+}
+
+// FieldChange is the difference of a single field as reported by Diff
+type FieldChange struct {
+	Field FieldID
+	Kind  ChangeKind
+	// Old and New are the values of the field in the compared containers. Both are nil if Redacted is set.
+	Old, New []byte
+	// Redacted is set for secret fields, see FieldID.IsSecret, whose values are not revealed
+	Redacted bool
+}
+
+// String returns a description of the change which is safe to log, e.g. "email: modified". Values are not
+// included.
+func (fc FieldChange) String() string {
+	if fc.Redacted {
+		return fmt.Sprintf("%s: %s (redacted)", fc.Field, fc.Kind)
+	}
+	return fmt.Sprintf("%s: %s", fc.Field, fc.Kind)
+}
+
+// MergePolicy selects the fields Merge takes from the other container
+type MergePolicy struct {
+	// Fields are the fields to take from the other container, all fields if empty
+	Fields []FieldID
+	// KeepExisting keeps fields which are present already, so only absent fields are filled
+	KeepExisting bool
+	// ClearAbsent removes fields which are absent in the other container. Otherwise, they are left unchanged.
+	ClearAbsent bool
+	// Extensions takes the extensions of the other container as well, subject to KeepExisting
+	Extensions bool
+}
+
+// Clone returns a deep copy of the container, including settings like the checksum algorithm or strict mode.
+// No value is shared with the original, so either may be modified without affecting the other.
+func (c *Container) Clone() *Container {
+	clone := *c
+	for id, f := range c.fields {
+		clone.fields[id] = bytes.Clone(f)
+	}
+	if c.extensions != nil {
+		clone.extensions = make([]Extension, len(c.extensions))
+		for i, e := range c.extensions {
+			e.Value = bytes.Clone(e.Value)
+			clone.extensions[i] = e
+		}
+	}
+	return &clone
+}
+
+// Equal reports whether both containers hold the same data, i.e. the same version, fields including their
+// presence, encryption and signing state, and extensions. Encoding settings like the checksum algorithm or
+// compression are ignored. The values are compared in constant time, so Equal may be used to compare secrets
+// like tokens; only their lengths are revealed by timing.
+func (c *Container) Equal(other *Container) bool {
+	if c == nil || other == nil {
+		return c == other
+	}
+
+	equal := subtle.ConstantTimeByteEq(c.versionMajor, other.versionMajor) &
+		subtle.ConstantTimeByteEq(c.versionMinor, other.versionMinor) &
+		subtle.ConstantTimeByteEq(c.versionPatch, other.versionPatch) &
+		subtle.ConstantTimeEq(int32(c.flags), int32(other.flags))
+	for id := range c.fields {
+		equal &= constantTimeEqual(c.fields[id], other.fields[id])
+	}
+
+	if len(c.extensions) != len(other.extensions) {
+		equal = 0
+	}
+	for i := range c.extensions {
+		e := &c.extensions[i]
+		o := other.extension(e.Key)
+		if o == nil || o.flags() != e.flags() {
+			equal = 0
+			continue
+		}
+		equal &= constantTimeEqual(e.Value, o.Value)
+	}
+	return equal == 1
+}
+
+// Diff returns the differences of the fields of c and other in wire order, nil if there are none. A field
+// counts as modified if its value or its encryption state differs. The values of secret fields are redacted.
+func (c *Container) Diff(other *Container) []FieldChange {
+	var changes []FieldChange
+	for id := FieldID(0); id < fieldCount; id++ {
+		var (
+			ours   = c.fields[id]
+			theirs = other.fields[id]
+			fc     = FieldChange{Field: id, Old: ours, New: theirs}
+		)
+		switch {
+		case ours == nil && theirs == nil:
+			continue
+		case ours == nil:
+			fc.Kind = ChangeAdded
+		case theirs == nil:
+			fc.Kind = ChangeRemoved
+		case constantTimeEqual(ours, theirs) == 1 && c.isEncrypted(id) == other.isEncrypted(id):
+			continue
+		default:
+			fc.Kind = ChangeModified
+		}
+		if id.IsSecret() {
+			fc.Old, fc.New, fc.Redacted = nil, nil, true
+		}
+		changes = append(changes, fc)
+	}
+	return changes
+}
+
+// Merge overlays the fields of other selected by the policy onto c. The values are copied. Both containers
+// have to be decrypted, since fields encrypted with different nonces or keys cannot be mixed. If a value does
+// not fit into c, e.g. because c compresses the field, an error is returned and c is left unchanged. A
+// signature is not updated, and the settings of c, e.g. defensive copies, are kept.
+func (c *Container) Merge(other *Container, policy MergePolicy) error {
+	if c.IsEncrypted() || other.IsEncrypted() {
+		return fmt.Errorf("cannot merge: container is %w", ErrAlreadyEncrypted)
+	}
+
+	fields := policy.Fields
+	if len(fields) == 0 {
+		fields = make([]FieldID, fieldCount)
+		for id := range fields {
+			fields[id] = FieldID(id)
+		}
+	}
+
+	result := c.Clone()
+	for _, id := range fields {
+		if !id.valid() {
+			return fmt.Errorf("unknown field %s", id)
+		}
+		if policy.KeepExisting && c.fields[id] != nil {
+			continue
+		}
+		if other.fields[id] == nil && !policy.ClearAbsent {
+			continue
+		}
+		if err := result.Set(id, bytes.Clone(other.fields[id])); err != nil {
+			return err
+		}
+	}
+
+	if policy.Extensions {
+		for _, e := range other.extensions {
+			if policy.KeepExisting && c.extension(e.Key) != nil {
+				continue
+			}
+			e.Value = bytes.Clone(e.Value)
+			if err := result.SetExtension(e); err != nil {
+				return err
+			}
+		}
+	}
+
+	c.assign(result)
+	return nil
+}
+
+// constantTimeEqual returns 1 if a and b are equal including their presence, 0 otherwise
+func constantTimeEqual(a, b []byte) int {
+	if (a == nil) != (b == nil) {
+		return 0
+	}
+	return subtle.ConstantTimeCompare(a, b)
+}
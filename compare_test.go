@@ -0,0 +1,177 @@
+package eraf
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func Test_Container_Clone(t *testing.T) {
+	c := New().SetEmail([]byte("someone@example.com")).SetUsername([]byte{}).SetChecksum(ChecksumCRC32C)
+	if err := c.SetExtension(Extension{Key: NumericKey(7), Value: []byte("seven")}); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+
+	clone := c.Clone()
+	if !clone.Equal(c) || !bytes.Equal(clone.MarshalBytes(), c.MarshalBytes()) {
+		t.Errorf("expected clone to equal the original")
+	}
+
+	clone.GetEmail()[0] = 'x'
+	v, _ := clone.GetExtension(NumericKey(7))
+	v[0] = 'x'
+	clone.SetToken([]byte("token"))
+	if string(c.GetEmail()) != "someone@example.com" || c.Has(FieldToken) {
+		t.Errorf("expected original fields to be unaffected, got email '%s'", c.GetEmail())
+	}
+	if v, _ = c.GetExtension(NumericKey(7)); string(v) != "seven" {
+		t.Errorf("expected original extension to be unaffected, got '%s'", v)
+	}
+}
+
+func Test_Container_Equal(t *testing.T) {
+	base := func() *Container {
+		c := New().SetVersionMajor(1).SetToken([]byte("token")).SetEmail([]byte{})
+		_ = c.SetExtension(Extension{Key: StringKey("tenant"), Value: []byte("acme")})
+		return c
+	}
+
+	tests := []struct {
+		name   string
+		modify func(c *Container)
+		equal  bool
+	}{
+		{"identical", func(c *Container) {}, true},
+		{"checksum ignored", func(c *Container) { c.SetChecksum(ChecksumSHA256) }, true},
+		{"compression ignored", func(c *Container) { _ = c.SetCompression(FieldToken, true) }, true},
+		{"version", func(c *Container) { c.SetVersionPatch(1) }, false},
+		{"value", func(c *Container) { c.SetToken([]byte("tokem")) }, false},
+		{"length", func(c *Container) { c.SetToken([]byte("token!")) }, false},
+		{"presence", func(c *Container) { c.Clear(FieldEmail) }, false},
+		{"signed", func(c *Container) { c.SetSigned(true) }, false},
+		{"extension value", func(c *Container) {
+			_ = c.SetExtension(Extension{Key: StringKey("tenant"), Value: []byte("acne")})
+		}, false},
+		{"extension key", func(c *Container) {
+			_ = c.SetExtension(Extension{Key: StringKey("tenant")})
+			_ = c.SetExtension(Extension{Key: StringKey("region"), Value: []byte("acme")})
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := base()
+			tt.modify(c)
+			if got := base().Equal(c); got != tt.equal {
+				t.Errorf("expected %t, got %t", tt.equal, got)
+			}
+			if got := c.Equal(base()); got != tt.equal {
+				t.Errorf("expected %t in reverse, got %t", tt.equal, got)
+			}
+		})
+	}
+
+	var nilContainer *Container
+	if base().Equal(nil) || !nilContainer.Equal(nil) {
+		t.Errorf("expected nil to equal nil only")
+	}
+}
+
+func Test_Container_Diff(t *testing.T) {
+	var (
+		stored = New().SetEmail([]byte("old@example.com")).SetToken([]byte("old")).SetUsername([]byte("someone"))
+		update = New().SetEmail([]byte("new@example.com")).SetToken([]byte("new")).SetUsername([]byte("someone")).
+			SetIdentifier([]byte("device-42"))
+	)
+
+	changes := stored.Diff(update)
+	expected := []FieldChange{
+		{Field: FieldIdentifier, Kind: ChangeAdded, New: []byte("device-42")},
+		{Field: FieldEmail, Kind: ChangeModified, Old: []byte("old@example.com"), New: []byte("new@example.com")},
+		{Field: FieldToken, Kind: ChangeModified, Redacted: true},
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("expected %d changes, got %v", len(expected), changes)
+	}
+	for i, e := range expected {
+		got := changes[i]
+		if got.Field != e.Field || got.Kind != e.Kind || got.Redacted != e.Redacted ||
+			!bytes.Equal(got.Old, e.Old) || !bytes.Equal(got.New, e.New) {
+			t.Errorf("expected %+v, got %+v", e, got)
+		}
+	}
+	if changes[2].String() != "token: modified (redacted)" || changes[0].String() != "identifier: added" {
+		t.Errorf("expected descriptions without values, got '%s' and '%s'", changes[2], changes[0])
+	}
+
+	if removed := update.Diff(stored); removed[0].Kind != ChangeRemoved || removed[0].New != nil {
+		t.Errorf("expected identifier to be removed, got %+v", removed[0])
+	}
+	if stored.Diff(stored.Clone()) != nil {
+		t.Errorf("expected no changes between clones")
+	}
+}
+
+func Test_Container_Merge(t *testing.T) {
+	other := New().SetEmail([]byte("new@example.com")).SetToken([]byte("new"))
+	_ = other.SetExtension(Extension{Key: StringKey("tenant"), Value: []byte("acme")})
+
+	tests := []struct {
+		name     string
+		policy   MergePolicy
+		email    string
+		username []byte
+		token    string
+		tenant   bool
+	}{
+		{"all", MergePolicy{}, "new@example.com", []byte("someone"), "new", false},
+		{"selected", MergePolicy{Fields: []FieldID{FieldToken}}, "old@example.com", []byte("someone"), "new", false},
+		{"keep existing", MergePolicy{KeepExisting: true}, "old@example.com", []byte("someone"), "new", false},
+		{"clear absent", MergePolicy{ClearAbsent: true}, "new@example.com", nil, "new", false},
+		{"extensions", MergePolicy{Fields: []FieldID{FieldEmail}, Extensions: true}, "new@example.com", []byte("someone"), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New().SetEmail([]byte("old@example.com")).SetUsername([]byte("someone"))
+			if err := c.Merge(other, tt.policy); err != nil {
+				t.Fatalf("expected no error, got %s", err.Error())
+			}
+			if string(c.GetEmail()) != tt.email || !bytes.Equal(c.GetUsername(), tt.username) || string(c.GetToken()) != tt.token {
+				t.Errorf("expected email '%s', username '%s' and token '%s', got '%s', '%s' and '%s'",
+					tt.email, tt.username, tt.token, c.GetEmail(), c.GetUsername(), c.GetToken())
+			}
+			if _, ok := c.GetExtension(StringKey("tenant")); ok != tt.tenant {
+				t.Errorf("expected extension to be merged: %t", tt.tenant)
+			}
+		})
+	}
+
+	c := New()
+	_ = c.Merge(other, MergePolicy{})
+	c.GetEmail()[0] = 'x'
+	if string(other.GetEmail()) != "new@example.com" {
+		t.Errorf("expected merged values to be copied")
+	}
+
+	c = New().SetDefensiveCopies(true).SetChecksum(ChecksumCRC32C)
+	_ = c.Merge(other, MergePolicy{})
+	c.GetEmail()[0] = 'x'
+	if string(c.GetEmail()) != "new@example.com" || c.Checksum() != ChecksumCRC32C {
+		t.Errorf("expected settings to be kept after merge")
+	}
+
+	c = New().SetEmail([]byte("old@example.com"))
+	if err := c.Merge(other, MergePolicy{Fields: []FieldID{FieldEmail, fieldCount}}); err == nil {
+		t.Errorf("expected error for unknown field")
+	}
+	if string(c.GetEmail()) != "old@example.com" {
+		t.Errorf("expected container to be unchanged after error")
+	}
+
+	encrypted := New().SetNonce([]byte("123456789012")).SetToken([]byte("token"))
+	if err := encrypted.EncryptEverything([]byte("123456789012"), []byte("0123456789abcdef")); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if err := New().Merge(encrypted, MergePolicy{}); !errors.Is(err, ErrAlreadyEncrypted) {
+		t.Errorf("expected ErrAlreadyEncrypted, got %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package eraf
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+)
+
+// DefaultMaxDecompressedSize is the default limit of a single decompressed field, see SetMaxDecompressedSize
+const DefaultMaxDecompressedSize = 1 << 20
+
+// Fields marked for compression are stored DEFLATE-compressed, which is recorded in the compression bitmap of the
+// meta data. The container itself always holds the uncompressed values, except for encrypted fields, which are
+// compressed before encryption.
+
+// SetCompression enables or disables compression of the given field. Since compressed fields are limited to
+// 65,535 bytes after compression, values of up to the maximum decompressed size can be set once compression
+// is enabled.
+func (c *Container) SetCompression(id FieldID, enabled bool) error {
+	if !id.valid() {
+		return fmt.Errorf("unknown field %s", id)
+	}
+	if c.isEncrypted(id) {
+		return fmt.Errorf("field %s: %w", id, ErrAlreadyEncrypted)
+	}
+	if enabled {
+		c.compressed |= 1 << id
+	} else {
+		c.compressed &^= 1 << id
+	}
+	return nil
+}
+
+// CompressedFields returns the fields which are compressed, in wire order
+func (c *Container) CompressedFields() []FieldID {
+	var ids []FieldID
+	for id := FieldID(0); id < fieldCount; id++ {
+		if c.isCompressed(id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// SetMaxDecompressedSize sets the maximum size of a single field after decompression, e.g. before unmarshalling
+// into the container. Larger fields are rejected to prevent decompression bombs. The default is
+// DefaultMaxDecompressedSize.
+func (c *Container) SetMaxDecompressedSize(n int) *Container {
+	c.maxDecompressed = n
+	return c
+}
+
+func (c *Container) isCompressed(id FieldID) bool {
+	return c.compressed&(1<<id) != 0
+}
+
+func (c *Container) maxDecompressedSize() int {
+	return decompressionLimit(c.maxDecompressed)
+}
+
+// decompressionLimit returns the configured limit n or the default if none is configured
+func decompressionLimit(n int) int {
+	if n <= 0 {
+		return DefaultMaxDecompressedSize
+	}
+	return n
+}
+
+// maxFieldSize returns the maximum size of the value of the given field
+func (c *Container) maxFieldSize(id FieldID) int {
+	if c.isCompressed(id) {
+		return c.maxDecompressedSize()
+	}
+	return blockMaxSize
+}
+
+// compressedValue returns the value of the given field as it is stored or encrypted, i.e. compressed if required.
+// Encrypted fields have already been compressed before encryption.
+func (c *Container) compressedValue(id FieldID) []byte {
+	if !c.isCompressed(id) || c.isEncrypted(id) || c.fields[id] == nil {
+		return c.fields[id]
+	}
+	return deflate(c.fields[id])
+}
+
+// wireFields returns the fields as they are stored
+func (c *Container) wireFields() [fieldCount][]byte {
+	fields := c.fields
+	if c.compressed == 0 {
+		return fields
+	}
+	for id := FieldID(0); id < fieldCount; id++ {
+		fields[id] = c.compressedValue(id)
+	}
+	return fields
+}
+
+func deflate(b []byte) []byte {
+	var buf bytes.Buffer
+	// only fails for invalid compression levels
+	w, _ := flate.NewWriter(&buf, flate.BestCompression)
+	_, _ = w.Write(b)
+	_ = w.Close()
+	return buf.Bytes()
+}
+
+// inflate decompresses b, failing if the result exceeds limit bytes
+func inflate(b []byte, limit int) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(b))
+	defer func() {
+		_ = r.Close()
+	}()
+	out, err := io.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > limit {
+		return nil, fmt.Errorf("decompressed size exceeds %d bytes", limit)
+	}
+	return out, nil
+}
@@ -0,0 +1,185 @@
+package eraf
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_Container_Compression(t *testing.T) {
+	chain := []byte(strings.Repeat("-----BEGIN CERTIFICATE-----\nMIIBszCCAVmgAwIBAgIUZ3Vlc3Q=\n-----END CERTIFICATE-----\n", 2000))
+	if len(chain) <= blockMaxSize {
+		t.Fatalf("expected chain to exceed %d bytes", blockMaxSize)
+	}
+
+	c := New()
+	if err := c.SetCompression(FieldRootCertificate, true); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	c.SetRootCertificate(chain).SetEmail([]byte("someone@example.com"))
+	if c.Err() != nil || !bytes.Equal(c.GetRootCertificate(), chain) {
+		t.Fatalf("expected compressed field to accept %d bytes, got %v", len(chain), c.Err())
+	}
+
+	b, err := c.MarshalBinary()
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if len(b) >= len(chain)/10 {
+		t.Errorf("expected compression, got %d bytes", len(b))
+	}
+
+	result := &Container{}
+	if err = UnmarshalBytes(b, result); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if !bytes.Equal(result.GetRootCertificate(), chain) || string(result.GetEmail()) != "someone@example.com" {
+		t.Errorf("expected fields to survive the round trip")
+	}
+	if ids := result.CompressedFields(); !result.IsCompressed() || len(ids) != 1 || ids[0] != FieldRootCertificate {
+		t.Errorf("expected root certificate to be compressed, got %v", ids)
+	}
+
+	if err = c.SetCompression(fieldCount, true); err == nil {
+		t.Errorf("expected error for unknown field")
+	}
+	if len(New().SetRootCertificate(chain).GetRootCertificate()) != blockMaxSize {
+		t.Errorf("expected uncompressed field to be truncated")
+	}
+}
+
+func Test_Container_Compression_Encryption(t *testing.T) {
+	var (
+		key   = []byte("0123456789abcdef")
+		nonce = []byte("123456789012")
+		cert  = []byte(strings.Repeat("certificate ", 10000))
+	)
+	c := New().SetNonce(nonce)
+	_ = c.SetCompression(FieldCertificate, true)
+	c.SetCertificate(cert)
+
+	if err := c.EncryptEverything(nonce, key); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if len(c.GetCertificate()) >= len(cert)/10 {
+		t.Errorf("expected compression before encryption, got %d bytes", len(c.GetCertificate()))
+	}
+	if err := c.SetCompression(FieldCertificate, false); err == nil {
+		t.Errorf("expected error changing compression of an encrypted field")
+	}
+
+	result := &Container{}
+	if err := UnmarshalBytes(c.MarshalBytes(), result); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if err := result.DecryptEverything(nonce, key); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if !bytes.Equal(result.GetCertificate(), cert) {
+		t.Errorf("expected certificate to survive encryption and compression")
+	}
+}
+
+func Test_Container_Compression_Encodings(t *testing.T) {
+	var (
+		key   = []byte("0123456789abcdef")
+		nonce = []byte("123456789012")
+		cert  = []byte(strings.Repeat("certificate ", 10000))
+	)
+	encrypted := New().SetNonce(nonce)
+	_ = encrypted.SetCompression(FieldCertificate, true)
+	encrypted.SetCertificate(cert)
+	if err := encrypted.EncryptEverything(nonce, key); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	plain := New()
+	_ = plain.SetCompression(FieldCertificate, true)
+	plain.SetCertificate(cert)
+
+	for _, enc := range alternateEncodings {
+		t.Run(enc.name, func(t *testing.T) {
+			for _, c := range []*Container{encrypted, plain} {
+				result, err := enc.roundTrip(c)
+				if err != nil {
+					t.Fatalf("expected no error, got %s", err.Error())
+				}
+				if !bytes.Equal(result.MarshalBytes(), c.MarshalBytes()) || !result.isCompressed(FieldCertificate) {
+					t.Fatalf("expected compression to survive the round trip, got %v", result.CompressedFields())
+				}
+				if result.IsEncrypted() {
+					if err = result.DecryptEverything(nonce, key); err != nil {
+						t.Fatalf("expected no error, got %s", err.Error())
+					}
+				}
+				if !bytes.Equal(result.GetCertificate(), cert) {
+					t.Errorf("expected decompressed certificate, got %d bytes", len(result.GetCertificate()))
+				}
+			}
+		})
+	}
+}
+
+func Test_Container_Compression_Limit(t *testing.T) {
+	c := New().SetMaxDecompressedSize(4 << 20)
+	_ = c.SetCompression(FieldToken, true)
+	c.SetToken(make([]byte, 2<<20))
+	b, err := c.MarshalBinary()
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+
+	if err = UnmarshalBytes(b, &Container{}); err == nil {
+		t.Errorf("expected error exceeding the default limit of %d bytes", DefaultMaxDecompressedSize)
+	}
+	if err = UnmarshalBytes(b, New().SetMaxDecompressedSize(2<<20)); err != nil {
+		t.Errorf("expected no error, got %s", err.Error())
+	}
+
+	var tooLarge *ErrFieldTooLarge
+	err = c.Set(FieldToken, make([]byte, 4<<20+1))
+	if !errors.As(err, &tooLarge) || tooLarge.Limit != 4<<20 || !strings.Contains(err.Error(), "maximum of 4194304 bytes") {
+		t.Errorf("expected ErrFieldTooLarge with the decompressed limit, got %v", err)
+	}
+
+	// corrupt the compressed token
+	b[len(b)-5] ^= 0xff
+	if err = UnmarshalBytes(b, New().SetMaxDecompressedSize(4<<20)); err == nil {
+		t.Errorf("expected error for corrupted compressed data")
+	}
+}
+
+func Test_Container_Compression_Incompressible(t *testing.T) {
+	value := make([]byte, blockMaxSize+1000)
+	_, _ = rand.Read(value)
+
+	c := New()
+	_ = c.SetCompression(FieldCertificate, true)
+	if c.SetCertificate(value); c.Err() != nil {
+		t.Fatalf("expected compressed field to accept %d bytes, got %v", len(value), c.Err())
+	}
+
+	var tooLarge *ErrFieldTooLarge
+	if err := c.Marshal(io.Discard); !errors.As(err, &tooLarge) || tooLarge.Field != FieldCertificate ||
+		tooLarge.Limit != blockMaxSize {
+		t.Errorf("expected ErrFieldTooLarge from Marshal, got %v", err)
+	}
+	if _, err := c.MarshalBinary(); !errors.As(err, &tooLarge) {
+		t.Errorf("expected ErrFieldTooLarge from MarshalBinary, got %v", err)
+	}
+	if err := NewEncoder(io.Discard).Encode(c); !errors.As(err, &tooLarge) {
+		t.Errorf("expected ErrFieldTooLarge from Encode, got %v", err)
+	}
+
+	file := filepath.Join(t.TempDir(), "test.eraf")
+	if err := c.MarshalToFile(file, 0600); !errors.As(err, &tooLarge) {
+		t.Errorf("expected ErrFieldTooLarge from MarshalToFile, got %v", err)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("expected no file to be written, got %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+package eraf
+
+import (
+	"encoding/asn1"
+	"fmt"
+	"math"
+)
+
+// derContainer is the ASN.1 representation of a *Container:
+//
+//	ERAFContainer ::= SEQUENCE {
+//	    version          Version,
+//	    nonce            [0]  IMPLICIT OCTET STRING OPTIONAL,
+//	    tag              [1]  IMPLICIT OCTET STRING OPTIONAL,
+//	    serialNumber     [2]  IMPLICIT OCTET STRING OPTIONAL,
+//	    identifier       [3]  IMPLICIT OCTET STRING OPTIONAL,
+//	    certificate      [4]  IMPLICIT OCTET STRING OPTIONAL,
+//	    privateKey       [5]  IMPLICIT OCTET STRING OPTIONAL,
+//	    email            [6]  IMPLICIT OCTET STRING OPTIONAL,
+//	    username         [7]  IMPLICIT OCTET STRING OPTIONAL,
+//	    token            [8]  IMPLICIT OCTET STRING OPTIONAL,
+//	    signature        [9]  IMPLICIT OCTET STRING OPTIONAL,
+//	    rootCertificate  [10] IMPLICIT OCTET STRING OPTIONAL,
+//	    password         [11] IMPLICIT OCTET STRING OPTIONAL,
+//	    flags            [12] IMPLICIT INTEGER (0..4294967295) OPTIONAL,
+//	    extensions       [13] IMPLICIT OCTET STRING OPTIONAL,
+//	    compressed       [14] IMPLICIT INTEGER (0..65535) OPTIONAL }
+//
+//	Version ::= SEQUENCE {
+//	    major  INTEGER (0..255),
+//	    minor  INTEGER (0..255),
+//	    patch  INTEGER (0..255) }
+//
+// Every field is limited to 65,535 bytes, or the maximum decompressed size if it is compressed. Compressed fields
+// are stored uncompressed unless they are encrypted. Absent fields are omitted, as are the flags describing
+// encryption and signing and the compression bitmap if they are zero. The extensions are stored as one
+// extension area like in the binary format, omitted if there are none.
+type derContainer struct {
+	Version         derVersion
+	Nonce           []byte `asn1:"optional,tag:0"`
+	Tag             []byte `asn1:"optional,tag:1"`
+	SerialNumber    []byte `asn1:"optional,tag:2"`
+	Identifier      []byte `asn1:"optional,tag:3"`
+	Certificate     []byte `asn1:"optional,tag:4"`
+	PrivateKey      []byte `asn1:"optional,tag:5"`
+	Email           []byte `asn1:"optional,tag:6"`
+	Username        []byte `asn1:"optional,tag:7"`
+	Token           []byte `asn1:"optional,tag:8"`
+	Signature       []byte `asn1:"optional,tag:9"`
+	RootCertificate []byte `asn1:"optional,tag:10"`
+	Password        []byte `asn1:"optional,tag:11"`
+	Flags           int64  `asn1:"optional,tag:12"`
+	Extensions      []byte `asn1:"optional,tag:13"`
+	Compressed      int    `asn1:"optional,tag:14"`
+}
+
+type derVersion struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+// MarshalDER serializes the container into a DER-encoded ASN.1 SEQUENCE, e.g. for use as the value of an
+// X.509 certificate extension or a CMS attribute. See derContainer for the ASN.1 definition.
+func (c *Container) MarshalDER() ([]byte, error) {
+	if err := c.checkSize(); err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(derContainer{
+		Version:         derVersion{int(c.versionMajor), int(c.versionMinor), int(c.versionPatch)},
+		Nonce:           c.fields[FieldNonce],
+		Tag:             c.fields[FieldTag],
+		SerialNumber:    c.fields[FieldSerialNumber],
+		Identifier:      c.fields[FieldIdentifier],
+		Certificate:     c.fields[FieldCertificate],
+		PrivateKey:      c.fields[FieldPrivateKey],
+		Email:           c.fields[FieldEmail],
+		Username:        c.fields[FieldUsername],
+		Token:           c.fields[FieldToken],
+		Signature:       c.fields[FieldSignature],
+		RootCertificate: c.fields[FieldRootCertificate],
+		Password:        c.fields[FieldPassword],
+		Flags:           int64(c.flags),
+		Extensions:      c.marshalExtensions(),
+		Compressed:      int(c.compressed),
+	})
+}
+
+// UnmarshalDER deserializes a DER-encoded container as written by MarshalDER into target. Trailing data is rejected,
+// as are compressed fields exceeding the maximum decompressed size of target. The settings of target are kept.
+func UnmarshalDER(b []byte, target *Container) error {
+	var v derContainer
+	rest, err := asn1.Unmarshal(b, &v)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return fmt.Errorf("trailing data after ASN.1 container")
+	}
+
+	for _, n := range []int{v.Version.Major, v.Version.Minor, v.Version.Patch} {
+		if n < 0 || n > 255 {
+			return fmt.Errorf("version element %d out of range", n)
+		}
+	}
+	if v.Flags < 0 || v.Flags > math.MaxUint32 {
+		return fmt.Errorf("flags %d out of range", v.Flags)
+	}
+	if v.Compressed < 0 || v.Compressed > math.MaxUint16 {
+		return fmt.Errorf("compression bitmap %d out of range", v.Compressed)
+	}
+
+	result := target.decodeTarget()
+	result.compressed = uint16(v.Compressed)
+	for id, f := range [][]byte{v.Nonce, v.Tag, v.SerialNumber, v.Identifier, v.Certificate, v.PrivateKey, v.Email,
+		v.Username, v.Token, v.Signature, v.RootCertificate, v.Password} {
+		if max := result.maxFieldSize(FieldID(id)); len(f) > max {
+			return &ErrFieldTooLarge{Field: FieldID(id), Size: len(f), Limit: max}
+		}
+	}
+	extensions, err := unmarshalExtensions(v.Extensions)
+	if err != nil {
+		return err
+	}
+
+	result.
+		SetVersionMajor(byte(v.Version.Major)).
+		SetVersionMinor(byte(v.Version.Minor)).
+		SetVersionPatch(byte(v.Version.Patch)).
+		SetNonce(v.Nonce).
+		SetTag(v.Tag).
+		SetSerialNumber(v.SerialNumber).
+		SetIdentifier(v.Identifier).
+		SetCertificate(v.Certificate).
+		SetPrivateKey(v.PrivateKey).
+		SetEmail(v.Email).
+		SetUsername(v.Username).
+		SetToken(v.Token).
+		SetSignature(v.Signature).
+		SetRootCertificate(v.RootCertificate).
+		SetPassword(v.Password)
+	// the extension and compression flags are derived on marshalling
+	result.flags = uint32(v.Flags) &^ (flagExtensions | flagCompressed)
+	result.extensions = extensions
+
+	target.assign(result)
+	return nil
+}
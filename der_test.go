@@ -0,0 +1,154 @@
+package eraf
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func Test_Container_MarshalDER(t *testing.T) {
+	tests := []struct {
+		name      string
+		container *Container
+	}{
+		{"empty", New()},
+		{"some fields", New().SetIdentifier([]byte("device-42")).SetEmail([]byte("someone@example.com"))},
+		{"all fields", New().
+			SetVersionMajor(1).SetVersionMinor(255).SetVersionPatch(7).
+			SetNonce([]byte{1}).SetTag([]byte{2}).SetSerialNumber([]byte{3}).SetIdentifier([]byte{4}).
+			SetCertificate(bytes.Repeat([]byte{5}, 300)).SetPrivateKey([]byte{6}).SetEmail([]byte{7}).
+			SetUsername([]byte{8}).SetToken([]byte{9}).SetSignature([]byte{10}).
+			SetRootCertificate([]byte{11}).SetPassword([]byte{12})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			der, err := tt.container.MarshalDER()
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err.Error())
+			}
+			result := &Container{}
+			if err = UnmarshalDER(der, result); err != nil {
+				t.Fatalf("expected no error, got %s", err.Error())
+			}
+			if !bytes.Equal(result.MarshalBytes(), tt.container.MarshalBytes()) {
+				t.Errorf("expected identical binary representation after DER round trip")
+			}
+		})
+	}
+
+	// SEQUENCE { SEQUENCE { INTEGER 0, INTEGER 0, INTEGER 0 } }
+	expected := []byte{0x30, 0x0b, 0x30, 0x09, 0x02, 0x01, 0x00, 0x02, 0x01, 0x00, 0x02, 0x01, 0x00}
+	der, _ := New().MarshalDER()
+	if !bytes.Equal(der, expected) {
+		t.Errorf("expected %x, got %x", expected, der)
+	}
+	// [3] IMPLICIT OCTET STRING
+	der, _ = New().SetIdentifier([]byte{0xaa}).MarshalDER()
+	if !bytes.HasSuffix(der, []byte{0x83, 0x01, 0xaa}) {
+		t.Errorf("expected identifier with context tag 3, got %x", der)
+	}
+}
+
+func Test_UnmarshalDER_Invalid(t *testing.T) {
+	valid, _ := New().SetEmail([]byte("a")).MarshalDER()
+	version256, _ := asn1.Marshal(derContainer{Version: derVersion{Major: 256}})
+
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{"empty", nil},
+		{"garbage", []byte{1, 2, 3}},
+		{"trailing data", append(append([]byte{}, valid...), 0)},
+		{"truncated", valid[:len(valid)-1]},
+		{"version out of range", version256},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := UnmarshalDER(tt.input, &Container{}); err == nil {
+				t.Errorf("expected an error, got nil")
+			}
+		})
+	}
+}
+
+func Test_Container_DER_X509Extension(t *testing.T) {
+	// 2.999 is the example arc, use an OID of your own organization instead
+	oid := asn1.ObjectIdentifier{2, 999, 1}
+	c := New().SetIdentifier([]byte("device-42")).SetSerialNumber([]byte{1, 2, 3})
+	value, err := c.MarshalDER()
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:    big.NewInt(1),
+		Subject:         pkix.Name{CommonName: "device-42"},
+		NotBefore:       time.Now(),
+		NotAfter:        time.Now().Add(time.Hour),
+		ExtraExtensions: []pkix.Extension{{Id: oid, Value: value}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oid) {
+			continue
+		}
+		result := &Container{}
+		if err = UnmarshalDER(ext.Value, result); err != nil {
+			t.Fatalf("expected no error, got %s", err.Error())
+		}
+		if !bytes.Equal(result.MarshalBytes(), c.MarshalBytes()) {
+			t.Errorf("expected identical container from certificate extension")
+		}
+		return
+	}
+	t.Errorf("expected certificate extension %s", oid)
+}
+
+func Test_UnmarshalDER_KeepsSettings(t *testing.T) {
+	c := New()
+	if err := c.SetCompression(FieldCertificate, true); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	b, err := c.SetCertificate(bytes.Repeat([]byte("A"), 1000)).MarshalDER()
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+
+	var tooLarge *ErrFieldTooLarge
+	if err := UnmarshalDER(b, New().SetMaxDecompressedSize(100)); !errors.As(err, &tooLarge) || tooLarge.Limit != 100 {
+		t.Errorf("expected *ErrFieldTooLarge with limit 100, got %v", err)
+	}
+
+	copying := New().SetDefensiveCopies(true).SetChecksum(ChecksumCRC32C)
+	if err := UnmarshalDER(b, copying); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	copying.GetCertificate()[0] = 'B'
+	if copying.GetCertificate()[0] != 'A' {
+		t.Errorf("expected defensive copies to be kept")
+	}
+	if copying.Checksum() != ChecksumCRC32C {
+		t.Errorf("expected checksum algorithm to be kept, got %v", copying.Checksum())
+	}
+}
@@ -0,0 +1,190 @@
+package eraf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	storeFileExtension = ".eraf"
+	storeFilePerms     = 0600
+	storeDirPerms      = 0700
+)
+
+// DirStore is a Store keeping every container in its own file inside a directory. On creation, all .eraf
+// files are read once to build an index of identifier, serial number, email and username, so Find does
+// not have to touch the disk. Existing files may have any name, new files are named after a hash of
+// identifier and serial number. Files are written atomically and are only readable by the owner.
+// It is safe for concurrent use, but the directory must not be modified by other processes at the same time.
+type DirStore struct {
+	dir   string
+	mu    sync.RWMutex
+	index map[string]dirEntry
+}
+
+type dirEntry struct {
+	file   string
+	fields Query
+}
+
+// NewDirStore opens the directory as *DirStore, creating it if necessary
+func NewDirStore(dir string) (*DirStore, error) {
+	if err := os.MkdirAll(dir, storeDirPerms); err != nil {
+		return nil, err
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &DirStore{
+		dir:   dir,
+		index: make(map[string]dirEntry),
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), storeFileExtension) {
+			continue
+		}
+		file := filepath.Join(dir, entry.Name())
+		c := New()
+		if err = UnmarshalFromFile(file, c); err != nil {
+			return nil, err
+		}
+		s.index[storeKey(c.fields[FieldIdentifier], c.fields[FieldSerialNumber])] = dirEntry{file: file, fields: indexFields(c)}
+	}
+
+	return s, nil
+}
+
+// Get returns the container with the given identifier and serial number
+func (s *DirStore) Get(identifier, serialNumber []byte) (*Container, error) {
+	key := storeKey(identifier, serialNumber)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entry, ok := s.index[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return readContainerFile(entry.file)
+}
+
+// Put writes the container into the directory. A container which does not fit into the header is rejected.
+func (s *DirStore) Put(c *Container) error {
+	key := storeKey(c.fields[FieldIdentifier], c.fields[FieldSerialNumber])
+	b, err := c.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file := filepath.Join(s.dir, key+storeFileExtension)
+	if err := writeFileAtomic(file, b, storeFilePerms); err != nil {
+		return err
+	}
+
+	// the container might have been loaded from a file with a different name
+	if old, ok := s.index[key]; ok && old.file != file {
+		_ = os.Remove(old.file)
+	}
+	s.index[key] = dirEntry{file: file, fields: indexFields(c)}
+	return nil
+}
+
+// Delete removes the file of the container with the given identifier and serial number
+func (s *DirStore) Delete(identifier, serialNumber []byte) error {
+	key := storeKey(identifier, serialNumber)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.index[key]
+	if !ok {
+		return ErrNotFound
+	}
+	if err := os.Remove(entry.file); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	delete(s.index, key)
+	return nil
+}
+
+// List returns all containers in the directory
+func (s *DirStore) List() ([]*Container, error) {
+	return s.Find(Query{})
+}
+
+// Find returns all containers matching the query. Only matching files are read.
+func (s *DirStore) Find(q Query) ([]*Container, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	files := make([]string, 0)
+	for _, entry := range s.index {
+		f := entry.fields
+		if q.matches(f.Identifier, f.SerialNumber, f.Email, f.Username) {
+			files = append(files, entry.file)
+		}
+	}
+	sort.Strings(files)
+
+	result := make([]*Container, 0, len(files))
+	for _, file := range files {
+		c, err := readContainerFile(file)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, c)
+	}
+	return result, nil
+}
+
+func readContainerFile(file string) (*Container, error) {
+	c := New()
+	if err := UnmarshalFromFile(file, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// indexFields returns the indexed fields of the container, copied so they do not keep the container alive
+func indexFields(c *Container) Query {
+	return Query{
+		Identifier:   append([]byte{}, c.fields[FieldIdentifier]...),
+		SerialNumber: append([]byte{}, c.fields[FieldSerialNumber]...),
+		Email:        append([]byte{}, c.fields[FieldEmail]...),
+		Username:     append([]byte{}, c.fields[FieldUsername]...),
+	}
+}
+
+// writeFileAtomic writes b into a temporary file in the same directory and renames it to file afterwards, so
+// file is either replaced completely or left unchanged
+func writeFileAtomic(file string, b []byte, perms os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(file), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(b)
+	if err == nil {
+		err = tmp.Chmod(perms)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), file)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
@@ -0,0 +1,184 @@
+package eraf
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DumpFormat is the output format of DumpWith and DumpUnsafe
+type DumpFormat uint8
+
+// The supported dump formats
+const (
+	// DumpText writes one "name: value" line per field
+	DumpText DumpFormat = iota
+	// DumpJSON writes a JSON object
+	DumpJSON
+	// DumpTable writes an aligned table with name, length and value of every field
+	DumpTable
+)
+
+// DumpOptions configure DumpWith and DumpUnsafe
+type DumpOptions struct {
+	Format DumpFormat
+	// Absent includes absent fields, which are omitted by default
+	Absent bool
+}
+
+// dumpEntry is a single field as written by the dump functions
+type dumpEntry struct {
+	Name     string `json:"name"`
+	Present  bool   `json:"present"`
+	Length   int    `json:"length"`
+	Value    string `json:"value,omitempty"`
+	Redacted bool   `json:"redacted,omitempty"`
+}
+
+// redactedHashSize is the number of bytes of the SHA-256 hash shown for redacted values, enough to tell whether
+// two values are the same, but not to guess them
+const redactedHashSize = 4
+
+// Dump writes the version and all present fields as text into an io.Writer. Secret fields, see FieldID.IsSecret,
+// are redacted and shown as their length and a prefix of their SHA-256 hash.
+func (c *Container) Dump(w io.Writer) {
+	if w == nil {
+		return
+	}
+	_ = c.dump(w, DumpOptions{}, false)
+}
+
+// DumpWith works like Dump, but writes the format given by the options and returns write errors
+func (c *Container) DumpWith(w io.Writer, opts DumpOptions) error {
+	return c.dump(w, opts, false)
+}
+
+// DumpUnsafe works like DumpWith, but reveals secret fields. It is meant for debugging only; never use it to
+// write logs.
+func (c *Container) DumpUnsafe(w io.Writer, opts DumpOptions) error {
+	return c.dump(w, opts, true)
+}
+
+func (c *Container) dump(w io.Writer, opts DumpOptions, unsafe bool) error {
+	entries := c.dumpEntries(opts.Absent, unsafe)
+
+	switch opts.Format {
+	case DumpText:
+		var b strings.Builder
+		_, _ = fmt.Fprintf(&b, "Semantic Version: %s\n", c.GetSemVer())
+		for _, e := range entries {
+			_, _ = fmt.Fprintf(&b, "%s: %s\n", e.Name, e.Value)
+		}
+		_, err := io.WriteString(w, b.String())
+		return err
+	case DumpJSON:
+		return json.NewEncoder(w).Encode(struct {
+			Version string      `json:"version"`
+			Fields  []dumpEntry `json:"fields"`
+		}{c.GetSemVer(), entries})
+	case DumpTable:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintf(tw, "version\t\t%s\n", c.GetSemVer())
+		for _, e := range entries {
+			if !e.Present {
+				_, _ = fmt.Fprintf(tw, "%s\t-\t\n", e.Name)
+				continue
+			}
+			_, _ = fmt.Fprintf(tw, "%s\t%d\t%s\n", e.Name, e.Length, escapeControl(e.Value))
+		}
+		return tw.Flush()
+	}
+	return fmt.Errorf("unknown dump format %d", opts.Format)
+}
+
+// dumpEntries returns the fields in wire order with their values rendered for output
+func (c *Container) dumpEntries(absent, unsafe bool) []dumpEntry {
+	entries := make([]dumpEntry, 0, fieldCount)
+	for id := FieldID(0); id < fieldCount; id++ {
+		v := c.fields[id]
+		if v == nil && !absent {
+			continue
+		}
+		e := dumpEntry{Name: id.String(), Present: v != nil, Length: len(v)}
+		switch {
+		case v == nil:
+		case id.IsSecret() && !unsafe:
+			e.Value, e.Redacted = redact(v), true
+		default:
+			e.Value = render(v)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// redact returns a representation of the secret v showing only its length and a prefix of its hash
+func redact(v []byte) string {
+	sum := sha256.Sum256(v)
+	return fmt.Sprintf("[redacted %d bytes sha256:%s]", len(v), hex.EncodeToString(sum[:redactedHashSize]))
+}
+
+// render returns v as is if it is printable text, e.g. PEM data or an email address, otherwise as hex
+func render(v []byte) string {
+	if !utf8.Valid(v) {
+		return "0x" + hex.EncodeToString(v)
+	}
+	for _, r := range string(v) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return "0x" + hex.EncodeToString(v)
+		}
+	}
+	return string(v)
+}
+
+var controlEscaper = strings.NewReplacer("\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+// escapeControl escapes line breaks and tabs, so a value fits into a single table cell
+func escapeControl(s string) string {
+	return controlEscaper.Replace(s)
+}
+
+// String returns a short description of the container which is safe to log: its version and the names and
+// lengths of the present fields, but no values, e.g. "eraf.Container{version: 1.0.0, email: 19 bytes}".
+func (c *Container) String() string {
+	var b strings.Builder
+	b.WriteString("eraf.Container{version: ")
+	b.WriteString(c.GetSemVer())
+	for id, f := range c.fields {
+		if f != nil {
+			_, _ = fmt.Fprintf(&b, ", %s: %d bytes", FieldID(id), len(f))
+		}
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// Format implements fmt.Formatter. The verbs %v, %s and %q write String. %+v additionally writes the values of
+// all fields, with secret fields redacted as by Dump. Secrets are never written. Unlike the other methods, Format
+// has a value receiver, so a Container value is not printed with its struct contents either, which would reveal
+// secrets.
+func (c Container) Format(f fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && f.Flag('+'):
+		var b strings.Builder
+		b.WriteString("eraf.Container{version: ")
+		b.WriteString(c.GetSemVer())
+		for _, e := range c.dumpEntries(false, false) {
+			_, _ = fmt.Fprintf(&b, ", %s: %q", e.Name, e.Value)
+		}
+		b.WriteString("}")
+		_, _ = io.WriteString(f, b.String())
+	case verb == 'v' || verb == 's':
+		_, _ = io.WriteString(f, c.String())
+	case verb == 'q':
+		_, _ = fmt.Fprintf(f, "%q", c.String())
+	default:
+		_, _ = fmt.Fprintf(f, "%%!%c(eraf.Container)", verb)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build go1.21
+
+package eraf
+
+import "log/slog"
+
+// LogValue implements slog.LogValuer, so containers can be passed to log/slog directly. The value is a group of
+// the version and all present fields, with secret fields redacted as by Dump. It requires Go 1.21 or newer.
+func (c *Container) LogValue() slog.Value {
+	entries := c.dumpEntries(false, false)
+	attrs := make([]slog.Attr, 0, len(entries)+1)
+	attrs = append(attrs, slog.String("version", c.GetSemVer()))
+	for _, e := range entries {
+		attrs = append(attrs, slog.String(e.Name, e.Value))
+	}
+	return slog.GroupValue(attrs...)
+}
@@ -0,0 +1,31 @@
+//go:build go1.21
+
+package eraf
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func Test_Container_LogValue(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	logger.Info("received", "container", dumpContainer())
+
+	if strings.Contains(buf.String(), dumpSecret) {
+		t.Fatalf("expected secrets to be redacted, got %s", buf.String())
+	}
+	var entry struct {
+		Container map[string]string
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if entry.Container["version"] != "1.0.0" || entry.Container["email"] != "someone@example.com" ||
+		!strings.HasPrefix(entry.Container["token"], "[redacted 12 bytes") {
+		t.Errorf("expected version, email and redacted token, got %v", entry.Container)
+	}
+}
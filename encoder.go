@@ -0,0 +1,38 @@
+package eraf
+
+import (
+	"io"
+	"sync"
+)
+
+// bufferPool holds the buffers used by Marshal
+var bufferPool = sync.Pool{
+	New: func() any {
+		return new([]byte)
+	},
+}
+
+// Encoder writes containers to an io.Writer. It reuses its buffer for every container, so once the buffer has
+// grown to the size of the largest container, encoding does not allocate unless fields are compressed.
+// An Encoder is not safe for concurrent use.
+type Encoder struct {
+	w   io.Writer
+	buf []byte
+}
+
+// NewEncoder returns an *Encoder writing to w
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode serializes the container and writes it to the underlying io.Writer. Like MarshalBinary, it returns an
+// error instead of writing a container whose fields or positions do not fit into the header.
+func (e *Encoder) Encode(c *Container) error {
+	b, err := c.marshalChecked(e.buf[:0])
+	if err != nil {
+		return err
+	}
+	e.buf = b
+	_, err = e.w.Write(b)
+	return err
+}
@@ -0,0 +1,41 @@
+package eraf
+
+import (
+	"bytes"
+	"encoding/base64"
+)
+
+// MarshalBinary implements encoding.BinaryMarshaler. Unlike MarshalBytes, it returns an error instead of
+// silently truncating fields and positions which do not fit into the header.
+func (c *Container) MarshalBinary() ([]byte, error) {
+	return c.marshalChecked(nil)
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. The given bytes are copied, so the caller may
+// reuse them afterwards.
+func (c *Container) UnmarshalBinary(b []byte) error {
+	return UnmarshalBytes(b, c)
+}
+
+// MarshalText implements encoding.TextMarshaler. The binary representation is encoded using URL-safe base64
+// without padding, so the result can be used in environment variables, command line flags or config files.
+func (c *Container) MarshalText() ([]byte, error) {
+	b, err := c.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	text := make([]byte, base64.RawURLEncoding.EncodedLen(len(b)))
+	base64.RawURLEncoding.Encode(text, b)
+	return text, nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. Surrounding whitespace and padding are ignored.
+func (c *Container) UnmarshalText(text []byte) error {
+	text = bytes.TrimRight(bytes.TrimSpace(text), "=")
+	b := make([]byte, base64.RawURLEncoding.DecodedLen(len(text)))
+	n, err := base64.RawURLEncoding.Decode(b, text)
+	if err != nil {
+		return err
+	}
+	return UnmarshalNoCopy(b[:n], c)
+}
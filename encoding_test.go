@@ -0,0 +1,148 @@
+package eraf
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/gob"
+	"strings"
+	"testing"
+)
+
+var (
+	_ encoding.BinaryMarshaler   = &Container{}
+	_ encoding.BinaryUnmarshaler = &Container{}
+	_ encoding.TextMarshaler     = &Container{}
+	_ encoding.TextUnmarshaler   = &Container{}
+)
+
+func Test_Container_MarshalBinary(t *testing.T) {
+	c := New().SetIdentifier([]byte("device-42")).SetToken([]byte("token")).SetPassword([]byte("pass"))
+	c.SetVersionMajor(1)
+
+	b, err := c.MarshalBinary()
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if !bytes.Equal(b, c.MarshalBytes()) {
+		t.Errorf("expected MarshalBinary to equal MarshalBytes")
+	}
+
+	result := &Container{}
+	if err = result.UnmarshalBinary(b); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	for i := range b {
+		b[i] = 0
+	}
+	if string(result.GetIdentifier()) != "device-42" || string(result.GetPassword()) != "pass" {
+		t.Errorf("expected fields to survive reuse of the input, got identifier '%s'", result.GetIdentifier())
+	}
+
+	if err = result.UnmarshalBinary([]byte{0, 3}); err == nil {
+		t.Errorf("expected error for truncated input")
+	}
+}
+
+func Test_Container_MarshalBinary_TooLarge(t *testing.T) {
+	big := make([]byte, blockMaxSize)
+	c := New().SetCertificate(big).SetEmail([]byte("a"))
+	if _, err := c.MarshalBinary(); err == nil {
+		t.Errorf("expected error for field positions exceeding the header")
+	}
+	if _, err := c.MarshalText(); err == nil {
+		t.Errorf("expected error for field positions exceeding the header")
+	}
+
+	c = New()
+	c.fields[FieldSignature] = make([]byte, blockMaxSize+1)
+	if _, err := c.MarshalBinary(); err == nil {
+		t.Errorf("expected error for field exceeding %d bytes", blockMaxSize)
+	}
+}
+
+func Test_Container_MarshalText(t *testing.T) {
+	c := New().SetNonce([]byte{0xfb, 0xff, 0xfe}).SetEmail([]byte("someone@example.com"))
+
+	text, err := c.MarshalText()
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if strings.ContainsAny(string(text), "+/=") {
+		t.Errorf("expected URL-safe base64 without padding, got %s", text)
+	}
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"plain", string(text)},
+		{"whitespace", " " + string(text) + "\n"},
+		{"padding", string(text) + strings.Repeat("=", (4-len(text)%4)%4)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := &Container{}
+			if err := result.UnmarshalText([]byte(tt.input)); err != nil {
+				t.Fatalf("expected no error, got %s", err.Error())
+			}
+			if !bytes.Equal(result.MarshalBytes(), c.MarshalBytes()) {
+				t.Errorf("expected identical container after text round trip")
+			}
+		})
+	}
+
+	if err = (&Container{}).UnmarshalText([]byte("not base64!")); err == nil {
+		t.Errorf("expected error for invalid input")
+	}
+}
+
+func Test_Container_Gob(t *testing.T) {
+	type envelope struct {
+		Name      string
+		Container *Container
+	}
+	in := envelope{"device", New().SetUsername([]byte("someone")).SetSignature([]byte{1, 2, 3})}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(in); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	var out envelope
+	if err := gob.NewDecoder(&buf).Decode(&out); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if out.Name != in.Name || !bytes.Equal(out.Container.MarshalBytes(), in.Container.MarshalBytes()) {
+		t.Errorf("expected identical container after gob round trip")
+	}
+}
+
+// alternateEncodings round trips a container through every encoding besides the binary format
+var alternateEncodings = []struct {
+	name      string
+	roundTrip func(c *Container) (*Container, error)
+}{
+	{"json", func(c *Container) (*Container, error) {
+		b, err := c.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		result := &Container{}
+		return result, result.UnmarshalJSON(b)
+	}},
+	{"der", func(c *Container) (*Container, error) {
+		b, err := c.MarshalDER()
+		if err != nil {
+			return nil, err
+		}
+		result := &Container{}
+		return result, UnmarshalDER(b, result)
+	}},
+	{"cbor", func(c *Container) (*Container, error) {
+		b, err := c.MarshalCBOR()
+		if err != nil {
+			return nil, err
+		}
+		result := &Container{}
+		return result, result.UnmarshalCBOR(b)
+	}},
+}
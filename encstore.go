@@ -0,0 +1,253 @@
+package eraf
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	envelopeVersion byte = 1
+	// version byte, nonce and the data key sealed under the master key
+	wrappedDataKeySize = 1 + 12 + masterKeySize + 16
+)
+
+// EncryptedStore wraps another Store and encrypts every container before it is handed to it. Each
+// container is encrypted with its own random data key, which is stored alongside, wrapped under the
+// master key of a *KeyRing. Adding or removing key slots therefore never requires re-encryption.
+//
+// Identifier and serial number are stored in plain text, so containers can still be looked up. All other
+// fields and the values of all extensions are encrypted using AES-256-GCM, each with its own random nonce. The wrapped data key is kept
+// in the tag field of the stored container, in front of the encrypted original tag. The data key and all
+// fields are bound to identifier and serial number as additional data, so encrypted records cannot be swapped
+// between containers.
+type EncryptedStore struct {
+	inner Store
+	ring  *KeyRing
+}
+
+// NewEncryptedStore creates an *EncryptedStore on top of inner. The key ring has to be unlocked
+// before the store can be used.
+func NewEncryptedStore(inner Store, ring *KeyRing) *EncryptedStore {
+	return &EncryptedStore{
+		inner: inner,
+		ring:  ring,
+	}
+}
+
+// Get returns the decrypted container with the given identifier and serial number
+func (s *EncryptedStore) Get(identifier, serialNumber []byte) (*Container, error) {
+	stored, err := s.inner.Get(identifier, serialNumber)
+	if err != nil {
+		return nil, err
+	}
+	return s.open(stored)
+}
+
+// Put encrypts the container and adds it to the underlying store
+func (s *EncryptedStore) Put(c *Container) error {
+	sealed, err := s.seal(c)
+	if err != nil {
+		return err
+	}
+	return s.inner.Put(sealed)
+}
+
+// Delete removes the container with the given identifier and serial number
+func (s *EncryptedStore) Delete(identifier, serialNumber []byte) error {
+	return s.inner.Delete(identifier, serialNumber)
+}
+
+// List returns all containers, decrypted
+func (s *EncryptedStore) List() ([]*Container, error) {
+	return s.Find(Query{})
+}
+
+// Find returns all containers matching the query. Since email and username are encrypted, queries
+// on these fields decrypt every container matching identifier and serial number.
+func (s *EncryptedStore) Find(q Query) ([]*Container, error) {
+	stored, err := s.inner.Find(Query{Identifier: q.Identifier, SerialNumber: q.SerialNumber})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*Container, 0, len(stored))
+	for _, sc := range stored {
+		c, err := s.open(sc)
+		if err != nil {
+			return nil, err
+		}
+		if q.Matches(c) {
+			result = append(result, c)
+		}
+	}
+	return result, nil
+}
+
+// envelopeFields are all fields which are encrypted at rest. The tag is handled separately.
+var envelopeFields = []FieldID{
+	FieldNonce, FieldCertificate, FieldPrivateKey, FieldEmail, FieldUsername,
+	FieldPassword, FieldToken, FieldSignature, FieldRootCertificate,
+}
+
+func (s *EncryptedStore) seal(c *Container) (*Container, error) {
+	if !s.ring.IsUnlocked() {
+		return nil, ErrKeyRingLocked
+	}
+
+	dataKey := make([]byte, masterKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, err
+	}
+	aad := envelopeAdditionalData(c.fields[FieldIdentifier], c.fields[FieldSerialNumber])
+	wrapped, err := s.ring.seal(dataKey, aad)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := New().
+		SetVersionMajor(c.versionMajor).
+		SetVersionMinor(c.versionMinor).
+		SetVersionPatch(c.versionPatch).
+		SetIdentifier(c.fields[FieldIdentifier]).
+		SetSerialNumber(c.fields[FieldSerialNumber])
+
+	for _, id := range envelopeFields {
+		if sealed.fields[id], err = sealRandomNonce(dataKey, c.fields[id], fieldAdditionalData(aad, id)); err != nil {
+			return nil, err
+		}
+	}
+	for _, e := range c.extensions {
+		if e.Value, err = sealRandomNonce(dataKey, e.Value, extensionAdditionalData(aad, e.Key)); err != nil {
+			return nil, err
+		}
+		sealed.extensions = append(sealed.extensions, e)
+	}
+	tag, err := sealRandomNonce(dataKey, c.fields[FieldTag], fieldAdditionalData(aad, FieldTag))
+	if err != nil {
+		return nil, err
+	}
+	sealed.fields[FieldTag] = append(append([]byte{envelopeVersion}, wrapped...), tag...)
+	// the flags describe the original fields, the envelope is recognized by its tag
+	sealed.flags = c.flags
+	sealed.compressed = c.compressed
+
+	// also rejects encrypted fields exceeding 65,535 bytes
+	if err = sealed.Validate(); err != nil {
+		return nil, err
+	}
+
+	return sealed, nil
+}
+
+func (s *EncryptedStore) open(sealed *Container) (*Container, error) {
+	if !s.ring.IsUnlocked() {
+		return nil, ErrKeyRingLocked
+	}
+	if len(sealed.fields[FieldTag]) < wrappedDataKeySize || sealed.fields[FieldTag][0] != envelopeVersion {
+		return nil, fmt.Errorf("container is not encrypted by an EncryptedStore")
+	}
+
+	aad := envelopeAdditionalData(sealed.fields[FieldIdentifier], sealed.fields[FieldSerialNumber])
+	dataKey, err := s.ring.open(sealed.fields[FieldTag][1:wrappedDataKeySize], aad)
+	if err != nil {
+		return nil, fmt.Errorf("could not unwrap data key: %w", err)
+	}
+
+	c := New().
+		SetVersionMajor(sealed.versionMajor).
+		SetVersionMinor(sealed.versionMinor).
+		SetVersionPatch(sealed.versionPatch).
+		SetIdentifier(sealed.fields[FieldIdentifier]).
+		SetSerialNumber(sealed.fields[FieldSerialNumber])
+
+	for _, id := range envelopeFields {
+		if c.fields[id], err = openRandomNonce(dataKey, sealed.fields[id], fieldAdditionalData(aad, id)); err != nil {
+			return nil, err
+		}
+	}
+	tag := sealed.fields[FieldTag][wrappedDataKeySize:]
+	if c.fields[FieldTag], err = openRandomNonce(dataKey, tag, fieldAdditionalData(aad, FieldTag)); err != nil {
+		return nil, err
+	}
+	for _, e := range sealed.extensions {
+		if e.Value, err = openRandomNonce(dataKey, e.Value, extensionAdditionalData(aad, e.Key)); err != nil {
+			return nil, err
+		}
+		c.extensions = append(c.extensions, e)
+	}
+	c.flags = sealed.flags
+	c.compressed = sealed.compressed
+	c.calculateHeaders()
+
+	return c, nil
+}
+
+// envelopeAdditionalData returns the additional data binding a sealed container to its identifier and serial
+// number. The identifier is prefixed with its length, so the boundary between both cannot be shifted.
+func envelopeAdditionalData(identifier, serialNumber []byte) []byte {
+	aad := make([]byte, 0, 4+len(identifier)+len(serialNumber))
+	aad = binary.BigEndian.AppendUint32(aad, uint32(len(identifier)))
+	aad = append(aad, identifier...)
+	return append(aad, serialNumber...)
+}
+
+// fieldAdditionalData extends the additional data of a container by the FieldID, so ciphertexts cannot be swapped
+// between fields
+func fieldAdditionalData(aad []byte, id FieldID) []byte {
+	return append(aad[:len(aad):len(aad)], 'f', byte(id))
+}
+
+// extensionAdditionalData extends the additional data of a container by the extension key, so ciphertexts cannot
+// be swapped between extensions or fields
+func extensionAdditionalData(aad []byte, key ExtensionKey) []byte {
+	aad = append(aad[:len(aad):len(aad)], 'x')
+	if key.numeric {
+		return binary.BigEndian.AppendUint32(append(aad, extNumeric), key.number)
+	}
+	return append(append(aad, 0), key.name...)
+}
+
+// sealRandomNonce encrypts b with AES-GCM using a random nonce, which is prepended to the result.
+// Absent (nil) input results in absent output, while empty input is encrypted to preserve its presence.
+func sealRandomNonce(key, b, aad []byte) ([]byte, error) {
+	if b == nil {
+		return nil, nil
+	}
+	aead, err := newGcm(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(b)+aead.Overhead())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, b, aad), nil
+}
+
+// openRandomNonce is the counterpart to sealRandomNonce
+func openRandomNonce(key, b, aad []byte) ([]byte, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+	aead, err := newGcm(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	// a non-nil destination keeps empty plaintexts present
+	return aead.Open([]byte{}, b[:aead.NonceSize()], b[aead.NonceSize():], aad)
+}
+
+func newGcm(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
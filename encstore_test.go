@@ -0,0 +1,155 @@
+package eraf
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func Test_EncryptedStore(t *testing.T) {
+	ring, err := NewKeyRing()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err = ring.AddPassphraseSlot("operator", []byte("passphrase")); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var (
+		inner = NewMemoryStore()
+		s     = NewEncryptedStore(inner, ring)
+	)
+	testStore(t, s)
+
+	c := New().SetIdentifier([]byte("device")).SetSerialNumber([]byte{9}).
+		SetNonce([]byte("nonce")).SetTag([]byte("tag")).
+		SetPrivateKey([]byte("very secret key")).SetEmail([]byte("device@example.com"))
+	if err = c.SetExtension(Extension{Key: StringKey("tenant"), Value: []byte("acme corp")}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err = s.Put(c); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	stored, err := inner.Get([]byte("device"), []byte{9})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if bytes.Contains(stored.MarshalBytes(), []byte("very secret key")) ||
+		bytes.Contains(stored.MarshalBytes(), []byte("device@example.com")) ||
+		bytes.Contains(stored.MarshalBytes(), []byte("acme corp")) {
+		t.Errorf("expected fields to be encrypted at rest")
+	}
+
+	// adding a slot does not affect stored containers
+	if err = ring.AddKeySlot("recovery", bytes.Repeat([]byte{1}, 32)); err != nil {
+		t.Fatal(err.Error())
+	}
+	ring.Lock()
+	if _, err = s.Get([]byte("device"), []byte{9}); !errors.Is(err, ErrKeyRingLocked) {
+		t.Errorf("expected ErrKeyRingLocked, got %v", err)
+	}
+	if err = ring.Unlock(bytes.Repeat([]byte{1}, 32)); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	got, err := s.Get([]byte("device"), []byte{9})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !bytes.Equal(got.MarshalBytes(), c.MarshalBytes()) {
+		t.Errorf("expected decrypted container to equal the original")
+	}
+
+	found, err := s.Find(Query{Email: []byte("device@example.com")})
+	if err != nil || len(found) != 1 {
+		t.Errorf("expected to find container by encrypted email, got %d and error %v", len(found), err)
+	}
+}
+
+func Test_EncryptedStore_Swap(t *testing.T) {
+	ring, err := NewKeyRing()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	var (
+		inner = NewMemoryStore()
+		s     = NewEncryptedStore(inner, ring)
+	)
+	for _, c := range []*Container{
+		New().SetIdentifier([]byte("alice")).SetSerialNumber([]byte{1}).SetToken([]byte("alice's token")),
+		New().SetIdentifier([]byte("mallory")).SetSerialNumber([]byte{1}).SetToken([]byte("mallory's token")),
+	} {
+		if err = s.Put(c); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	// an attacker with write access to the store replaces alice's record with mallory's
+	forged, err := inner.Get([]byte("mallory"), []byte{1})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	forged.SetIdentifier([]byte("alice"))
+	if err = inner.Put(forged); err != nil {
+		t.Fatal(err.Error())
+	}
+	if c, err := s.Get([]byte("alice"), []byte{1}); err == nil {
+		t.Errorf("expected swapped record to fail, got token '%s'", c.GetToken())
+	}
+
+	// the boundary between identifier and serial number is bound as well
+	forged.SetIdentifier([]byte("mallory\x01")).SetSerialNumber([]byte{})
+	if err = inner.Put(forged); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err = s.Get([]byte("mallory\x01"), []byte{}); err == nil {
+		t.Errorf("expected record with shifted serial number to fail")
+	}
+}
+
+func Test_EncryptedStore_SwapFields(t *testing.T) {
+	ring, err := NewKeyRing()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	var (
+		inner = NewMemoryStore()
+		s     = NewEncryptedStore(inner, ring)
+		c     = New().SetIdentifier([]byte("device")).SetSerialNumber([]byte{1}).
+			SetCertificate([]byte("device certificate")).SetRootCertificate([]byte("root certificate"))
+	)
+	_ = c.SetExtension(Extension{Key: StringKey("tenant"), Value: []byte("acme")})
+	_ = c.SetExtension(Extension{Key: StringKey("region"), Value: []byte("eu")})
+	if err = s.Put(c); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	tests := []struct {
+		name string
+		swap func(sealed *Container)
+	}{
+		{"fields", func(sealed *Container) {
+			sealed.fields[FieldCertificate], sealed.fields[FieldRootCertificate] =
+				sealed.fields[FieldRootCertificate], sealed.fields[FieldCertificate]
+		}},
+		{"extensions", func(sealed *Container) {
+			sealed.extensions[0].Value, sealed.extensions[1].Value = sealed.extensions[1].Value, sealed.extensions[0].Value
+		}},
+		{"field and extension", func(sealed *Container) {
+			sealed.extensions[0].Value = sealed.fields[FieldCertificate]
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sealed, err := inner.Get([]byte("device"), []byte{1})
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			tt.swap(sealed)
+			if _, err = s.open(sealed); err == nil {
+				t.Errorf("expected swapped ciphertexts to fail")
+			}
+		})
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"crypto/x509"
 	"encoding/binary"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -21,7 +22,7 @@ const (
 
 var (
 	headerBlock = [headerSize]byte{
-		0, 3, // version (3 bytes)
+		0, 3, // version (position, length)
 		0, 0, 0, 0, // nonce
 		0, 0, 0, 0, // tag
 		0, 0, 0, 0, // serial number
@@ -30,31 +31,30 @@ var (
 		0, 0, 0, 0, // private key
 		0, 0, 0, 0, // email
 		0, 0, 0, 0, // username
-		0, 0, 0, 0, // password
 		0, 0, 0, 0, // token
 		0, 0, 0, 0, // signature
 		0, 0, 0, 0, // root certificate
+		0, 0, 0, 0, // password
 	}
 )
 
 // Container is the central struct to work with
 type Container struct {
-	headers         [headerSize]byte
-	versionMajor    byte
-	versionMinor    byte
-	versionPatch    byte
-	nonce           []byte
-	tag             []byte
-	serialNumber    []byte
-	identifier      []byte
-	rootCertificate []byte
-	certificate     []byte
-	privateKey      []byte
-	email           []byte
-	username        []byte
-	password        []byte
-	token           []byte
-	signature       []byte
+	headers      [headerSize]byte
+	versionMajor byte
+	versionMinor byte
+	versionPatch byte
+	fields       [fieldCount][]byte
+	flags        uint32
+	extensions   []Extension
+	checksum     Checksum
+	compressed   uint16
+	strict       bool
+	lenient      bool
+	copying      bool
+
+	maxDecompressed int
+	err             error
 }
 
 // New creates a new *Container. Just convenience, not necessary.
@@ -64,6 +64,29 @@ func New() *Container {
 	}
 }
 
+// decodeTarget returns an empty container with the settings of c, so decoding into it enforces the limits of c
+func (c *Container) decodeTarget() *Container {
+	target := New()
+	target.strict = c.strict
+	target.lenient = c.lenient
+	target.copying = c.copying
+	target.maxDecompressed = c.maxDecompressed
+	return target
+}
+
+// assign replaces the version, fields, flags and extensions of c with the ones of other. Settings like the
+// checksum algorithm, defensive copies or the maximum decompressed size are kept.
+func (c *Container) assign(other *Container) {
+	c.versionMajor = other.versionMajor
+	c.versionMinor = other.versionMinor
+	c.versionPatch = other.versionPatch
+	c.fields = other.fields
+	c.flags = other.flags
+	c.compressed = other.compressed
+	c.extensions = other.extensions
+	c.calculateHeaders()
+}
+
 // GetVersionMajor returns the major version
 func (c *Container) GetVersionMajor() byte {
 	return c.versionMajor
@@ -99,182 +122,133 @@ func (c *Container) SetVersionPatch(v byte) *Container {
 
 // GetNonce returns the nonce
 func (c *Container) GetNonce() []byte {
-	return c.nonce
+	return c.get(FieldNonce)
 }
 
 // SetNonce sets a nonce
 func (c *Container) SetNonce(n []byte) *Container {
-	if len(n) <= blockMaxSize {
-		c.nonce = n
-	} else {
-		c.nonce = n[:blockMaxSize]
-	}
+	c.set(FieldNonce, n)
 	return c
 }
 
 // GetTag returns the tag
 func (c *Container) GetTag() []byte {
-	return c.tag
+	return c.get(FieldTag)
 }
 
 // SetTag sets a tag
 func (c *Container) SetTag(t []byte) *Container {
-	if len(t) <= blockMaxSize {
-		c.tag = t
-	} else {
-		c.tag = t[:blockMaxSize]
-	}
-	c.calculateHeaders()
+	c.set(FieldTag, t)
 	return c
 }
 
 // GetSerialNumber returns the serial number
 func (c *Container) GetSerialNumber() []byte {
-	return c.serialNumber
+	return c.get(FieldSerialNumber)
 }
 
 // SetSerialNumber sets a serial number
 func (c *Container) SetSerialNumber(sn []byte) *Container {
-	if len(sn) <= blockMaxSize {
-		c.serialNumber = sn
-	} else {
-		c.serialNumber = sn[:blockMaxSize]
-	}
+	c.set(FieldSerialNumber, sn)
 	return c
 }
 
 // GetIdentifier returns the identifier
 func (c *Container) GetIdentifier() []byte {
-	return c.identifier
+	return c.get(FieldIdentifier)
 }
 
 // SetIdentifier sets an identifier
 func (c *Container) SetIdentifier(id []byte) *Container {
-	if len(id) <= blockMaxSize {
-		c.identifier = id
-	} else {
-		c.identifier = id[:blockMaxSize]
-	}
+	c.set(FieldIdentifier, id)
 	return c
 }
 
 // GetRootCertificate returns the root certificate
 func (c *Container) GetRootCertificate() []byte {
-	return c.rootCertificate
+	return c.get(FieldRootCertificate)
 }
 
 // SetRootCertificate sets a root certificate
 func (c *Container) SetRootCertificate(rc []byte) *Container {
-	if len(rc) <= blockMaxSize {
-		c.rootCertificate = rc
-	} else {
-		c.rootCertificate = rc[:blockMaxSize]
-	}
+	c.set(FieldRootCertificate, rc)
 	return c
 }
 
 // GetCertificate returns the certificate
 func (c *Container) GetCertificate() []byte {
-	return c.certificate
+	return c.get(FieldCertificate)
 }
 
 // SetCertificate sets a certificate. For the convenience functions to work properly, the certificate expected to be in PEM format
 func (c *Container) SetCertificate(cert []byte) *Container {
-	if len(cert) <= blockMaxSize {
-		c.certificate = cert
-	} else {
-		c.certificate = cert[:blockMaxSize]
-	}
+	c.set(FieldCertificate, cert)
 	return c
 }
 
 // GetPrivateKey returns the private key
 func (c *Container) GetPrivateKey() []byte {
-	return c.privateKey
+	return c.get(FieldPrivateKey)
 }
 
 // SetPrivateKey sets a private key. For the convenience functions to work properly, the key is expected to be in PEM format
 func (c *Container) SetPrivateKey(pk []byte) *Container {
-	if len(pk) <= blockMaxSize {
-		c.privateKey = pk
-	} else {
-		c.privateKey = pk[:blockMaxSize]
-	}
+	c.set(FieldPrivateKey, pk)
 	return c
 }
 
 // GetEmail returns the email address
 func (c *Container) GetEmail() []byte {
-	return c.email
+	return c.get(FieldEmail)
 }
 
 // SetEmail sets an email address
 func (c *Container) SetEmail(e []byte) *Container {
-	if len(e) <= blockMaxSize {
-		c.email = e
-	} else {
-		c.email = e[:blockMaxSize]
-	}
+	c.set(FieldEmail, e)
 	return c
 }
 
 // GetUsername returns the username
 func (c *Container) GetUsername() []byte {
-	return c.username
+	return c.get(FieldUsername)
 }
 
 // SetUsername sets a username
 func (c *Container) SetUsername(u []byte) *Container {
-	if len(u) <= blockMaxSize {
-		c.username = u
-	} else {
-		c.username = u[:blockMaxSize]
-	}
+	c.set(FieldUsername, u)
 	return c
 }
 
 // GetPassword returns the password
 func (c *Container) GetPassword() []byte {
-	return c.password
+	return c.get(FieldPassword)
 }
 
 // SetPassword sets a password
 func (c *Container) SetPassword(p []byte) *Container {
-	if len(p) <= blockMaxSize {
-		c.password = p
-	} else {
-		c.password = p[:blockMaxSize]
-	}
+	c.set(FieldPassword, p)
 	return c
 }
 
 // GetToken returns the token
 func (c *Container) GetToken() []byte {
-	return c.token
+	return c.get(FieldToken)
 }
 
 // SetToken sets a token
 func (c *Container) SetToken(t []byte) *Container {
-	if len(t) <= blockMaxSize {
-		c.token = t
-	} else {
-		c.token = t[:blockMaxSize]
-	}
+	c.set(FieldToken, t)
 	return c
 }
 
 // GetSignature returns the signature
 func (c *Container) GetSignature() []byte {
-	return c.signature
+	return c.get(FieldSignature)
 }
 
 // SetSignature sets a signature
 func (c *Container) SetSignature(sig []byte) *Container {
-	if len(sig) <= blockMaxSize {
-		c.signature = sig
-	} else {
-		c.signature = sig[:blockMaxSize]
-	}
+	c.set(FieldSignature, sig)
 	return c
 }
 
@@ -283,12 +257,23 @@ func (c *Container) GetSemVer() string {
 	return fmt.Sprintf("%d.%d.%d", c.versionMajor, c.versionMinor, c.versionPatch)
 }
 
+// SetSemVer parses a semantic version string like 2.14.8 and sets all version elements
+func (c *Container) SetSemVer(v string) error {
+	var major, minor, patch byte
+	n, err := fmt.Sscanf(v, "%d.%d.%d", &major, &minor, &patch)
+	if err != nil || n != 3 || fmt.Sprintf("%d.%d.%d", major, minor, patch) != v {
+		return fmt.Errorf("invalid version '%s', expected major.minor.patch", v)
+	}
+	c.versionMajor, c.versionMinor, c.versionPatch = major, minor, patch
+	return nil
+}
+
 // GetX509Certificate returns the certificate as *x509.Certificate
 func (c *Container) GetX509Certificate() (*x509.Certificate, error) {
-	if c.certificate == nil {
+	if c.fields[FieldCertificate] == nil {
 		return nil, fmt.Errorf("certificate is nil")
 	}
-	block, _ := pem.Decode(c.certificate)
+	block, _ := pem.Decode(c.fields[FieldCertificate])
 	if block == nil {
 		return nil, fmt.Errorf("PEM block is nil")
 	}
@@ -297,23 +282,23 @@ func (c *Container) GetX509Certificate() (*x509.Certificate, error) {
 
 // GetTlsCertificate returns the certificate as *tls.Certificate
 func (c *Container) GetTlsCertificate() (*tls.Certificate, error) {
-	if c.certificate == nil {
+	if c.fields[FieldCertificate] == nil {
 		return nil, fmt.Errorf("certificate is nil")
 	}
-	if c.privateKey == nil {
+	if c.fields[FieldPrivateKey] == nil {
 		return nil, fmt.Errorf("private key is nil")
 	}
 
-	cert, err := tls.X509KeyPair(c.certificate, c.privateKey)
+	cert, err := tls.X509KeyPair(c.fields[FieldCertificate], c.fields[FieldPrivateKey])
 	return &cert, err
 }
 
 // GetX509RootCertificate returns the root certificate as *x509.Certificate
 func (c *Container) GetX509RootCertificate() (*x509.Certificate, error) {
-	if c.rootCertificate == nil {
+	if c.fields[FieldRootCertificate] == nil {
 		return nil, fmt.Errorf("root certificate is nil")
 	}
-	block, _ := pem.Decode(c.rootCertificate)
+	block, _ := pem.Decode(c.fields[FieldRootCertificate])
 	if block == nil {
 		return nil, fmt.Errorf("PEM block is nil")
 	}
@@ -322,7 +307,7 @@ func (c *Container) GetX509RootCertificate() (*x509.Certificate, error) {
 
 // Len returns the total amount of bytes of the file
 func (c *Container) Len() int {
-	return c.HeaderLen() + c.PayloadLen()
+	return c.HeaderLen() + c.PayloadLen() + c.checksum.trailerLen()
 }
 
 // HeaderLen returns the amount of bytes the header consists of
@@ -332,8 +317,8 @@ func (c *Container) HeaderLen() int {
 
 // PayloadLen returns the amount of bytes the payload takes up
 func (c *Container) PayloadLen() int {
-	return 3 + len(c.nonce) + len(c.tag) + len(c.serialNumber) + len(c.identifier) + len(c.certificate) +
-		len(c.privateKey) + len(c.email) + len(c.username) + len(c.token) + len(c.signature)
+	fields := c.wireFields()
+	return c.payloadLen(&fields)
 }
 
 // Read reads all bytes into s and returns the number of bytes read as well as an error
@@ -352,29 +337,58 @@ func (c *Container) Headers() [headerSize]byte {
 
 // Payload returns just the payload part of the ERAF file
 func (c *Container) Payload() []byte {
-	b := append([]byte{c.versionMajor, c.versionMinor, c.versionPatch}, c.nonce...)
-	b = append(b, c.tag...)
-	b = append(b, c.serialNumber...)
-	b = append(b, c.identifier...)
-	b = append(b, c.certificate...)
-	b = append(b, c.privateKey...)
-	b = append(b, c.email...)
-	b = append(b, c.username...)
-	b = append(b, c.token...)
-	return append(b, c.signature...)
-}
-
-// Marshal serializes the ERAF file into the given io.Writer
+	fields := c.wireFields()
+	return c.appendPayload(make([]byte, 0, c.payloadLen(&fields)), &fields)
+}
+
+// payloadLen returns the length of the payload consisting of the given wire fields
+func (c *Container) payloadLen(fields *[fieldCount][]byte) int {
+	n := c.versionLen()
+	for _, f := range fields {
+		n += len(f)
+	}
+	return n + c.extensionsLen()
+}
+
+// appendPayload appends the payload consisting of the given wire fields to b
+func (c *Container) appendPayload(b []byte, fields *[fieldCount][]byte) []byte {
+	b = append(b, c.versionMajor, c.versionMinor, c.versionPatch)
+	b = c.appendMeta(b)
+	for _, f := range fields {
+		b = append(b, f...)
+	}
+	return c.appendExtensions(b)
+}
+
+// Marshal serializes the ERAF file into the given io.Writer. The buffer is taken from a pool, so repeated
+// calls do not allocate a new one every time. Like MarshalBinary, it returns an error instead of writing a
+// container whose fields or positions do not fit into the header.
 func (c *Container) Marshal(w io.Writer) error {
-	total := c.MarshalBytes()
-	_, err := w.Write(total)
+	buf := bufferPool.Get().(*[]byte)
+	defer bufferPool.Put(buf)
+
+	b, err := c.marshalChecked((*buf)[:0])
+	if err != nil {
+		return err
+	}
+	*buf = b
+	_, err = w.Write(b)
 
 	return err
 }
 
-// MarshalToFile serializes the ERAF file into the given file using the given file permissions
+// MarshalToFile serializes the ERAF file into the given file using the given file permissions. If the container
+// does not fit into the header, an error is returned and the file is left untouched.
 func (c *Container) MarshalToFile(file string, perms os.FileMode) error {
-	c.calculateHeaders()
+	buf := bufferPool.Get().(*[]byte)
+	defer bufferPool.Put(buf)
+
+	b, err := c.marshalChecked((*buf)[:0])
+	if err != nil {
+		return err
+	}
+	*buf = b
+
 	fh, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perms)
 	if err != nil {
 		return err
@@ -383,30 +397,54 @@ func (c *Container) MarshalToFile(file string, perms os.FileMode) error {
 		_ = fh.Close()
 	}()
 
-	return c.Marshal(fh)
+	_, err = fh.Write(b)
+	return err
 }
 
-// MarshalBytes serializes the container into a []byte
+// MarshalBytes serializes the container into a []byte. The size of the container is not checked, so fields and
+// positions which do not fit into the header are silently truncated, resulting in a corrupt container. Only use
+// it for containers known to fit, e.g. after Validate, and MarshalBinary otherwise.
 func (c *Container) MarshalBytes() []byte {
-	c.calculateHeaders()
+	return c.MarshalAppend(nil)
+}
 
-	payload := append([]byte{c.versionMajor, c.versionMinor, c.versionPatch}, c.nonce...)
-	payload = append(payload, c.tag...)
-	payload = append(payload, c.serialNumber...)
-	payload = append(payload, c.identifier...)
-	payload = append(payload, c.certificate...)
-	payload = append(payload, c.privateKey...)
-	payload = append(payload, c.email...)
-	payload = append(payload, c.username...)
-	payload = append(payload, c.token...)
-	payload = append(payload, c.signature...)
+// MarshalAppend serializes the container and appends it to dst, returning the extended slice. dst is grown at
+// most once to the exact length of the container, so reusing the returned slice as dst, e.g. as dst[:0], avoids
+// allocations entirely unless fields are compressed. Like MarshalBytes, it does not check the size of the
+// container; use an Encoder to write containers which may not fit.
+func (c *Container) MarshalAppend(dst []byte) []byte {
+	fields := c.wireFields()
+	return c.appendWire(dst, &fields)
+}
 
-	h := c.Headers()
-	total := append(h[:], payload...)
-	return total
+// marshalChecked works like MarshalAppend, but returns an error instead of truncating fields and positions which
+// do not fit into the header. Compressed fields are compressed only once for checking and marshalling.
+func (c *Container) marshalChecked(dst []byte) ([]byte, error) {
+	fields := c.wireFields()
+	if err := c.checkWireSize(&fields); err != nil {
+		return nil, err
+	}
+	return c.appendWire(dst, &fields), nil
 }
 
-// UnmarshalFromFile deserializes a ERAF from the given file
+// appendWire appends the container consisting of the given wire fields to dst
+func (c *Container) appendWire(dst []byte, fields *[fieldCount][]byte) []byte {
+	c.writeHeaders(fields)
+
+	n := int(headerSize) + c.payloadLen(fields) + c.checksum.trailerLen()
+	if cap(dst)-len(dst) < n {
+		grown := make([]byte, len(dst), len(dst)+n)
+		copy(grown, dst)
+		dst = grown
+	}
+
+	start := len(dst)
+	dst = append(dst, c.headers[:]...)
+	dst = c.appendPayload(dst, fields)
+	return appendTrailer(dst, start, c.checksum)
+}
+
+// UnmarshalFromFile deserializes a ERAF from the given file, which may be binary or armored
 func UnmarshalFromFile(file string, target *Container) error {
 	reader, err := os.Open(file)
 	if err != nil {
@@ -419,7 +457,7 @@ func UnmarshalFromFile(file string, target *Container) error {
 	return Unmarshal(reader, target)
 }
 
-// Unmarshal deserializes a ERAF file from the io.Reader into a *Container
+// Unmarshal deserializes a ERAF file from the io.Reader into a *Container. Armored input is detected automatically.
 func Unmarshal(r io.Reader, target *Container) error {
 	allBytes, err := ioutil.ReadAll(r)
 	if err != nil {
@@ -430,7 +468,11 @@ func Unmarshal(r io.Reader, target *Container) error {
 		return fmt.Errorf("read 0 bytes")
 	}
 
-	return UnmarshalBytes(allBytes, target)
+	if isArmored(allBytes) {
+		_, err = UnmarshalArmored(allBytes, target)
+		return err
+	}
+	return UnmarshalNoCopy(allBytes, target)
 }
 
 // UnmarshalBytes takes a []byte and a pointer to a target container and deserializes the []byte into the container,
@@ -439,175 +481,213 @@ func Unmarshal(r io.Reader, target *Container) error {
 //  var b []byte // some data source
 //  var c *eraf.Container = eraf.New()
 //  err := eraf.Unmarshal(b, c)
+//
+// The data is copied, so the caller may modify or reuse allBytes afterwards.
 func UnmarshalBytes(allBytes []byte, target *Container) error {
-	if len(allBytes) < int(headerSize) {
-		return fmt.Errorf("byte slice is not large enough")
+	return unmarshalBytes(allBytes, target, true)
+}
+
+// UnmarshalNoCopy works like UnmarshalBytes, but the fields and extension values of target refer to allBytes
+// instead of a copy. This saves an allocation, e.g. for large certificates, but allBytes must neither be modified
+// nor reused as long as target is in use.
+func UnmarshalNoCopy(allBytes []byte, target *Container) error {
+	return unmarshalBytes(allBytes, target, false)
+}
+
+func unmarshalBytes(allBytes []byte, target *Container, copying bool) error {
+	headers, payload, checksumErr, err := frame(allBytes, target.lenient)
+	if err != nil {
+		return err
 	}
-	headers := allBytes[:headerSize]
 	copy(target.headers[:], headers)
-	payload := allBytes[headerSize:]
+	if copying {
+		payload = append([]byte(nil), payload...)
+	}
 
 	// Version
-	versionPosition := headers[0]
-	versionLength := headers[1]
+	// positions and lengths are converted to int before adding them, so they cannot wrap around
+	versionPosition := int(headers[0])
+	versionLength := int(headers[1])
 	versionBytes := payload[versionPosition : versionPosition+versionLength]
 	target.versionMajor = versionBytes[0]
 	target.versionMinor = versionBytes[1]
 	target.versionPatch = versionBytes[2]
 
-	// nonce
-	noncePosition := binary.BigEndian.Uint16(headers[2:4])
-	nonceLength := binary.BigEndian.Uint16(headers[4:6])
-	nonceBytes := payload[noncePosition : noncePosition+nonceLength]
-	target.nonce = nonceBytes
-
-	// tag
-	tagPosition := binary.BigEndian.Uint16(headers[6:8])
-	tagLength := binary.BigEndian.Uint16(headers[8:10])
-	tagBytes := payload[tagPosition : tagPosition+tagLength]
-	target.tag = tagBytes
-
-	// serial number
-	snPosition := binary.BigEndian.Uint16(headers[10:12])
-	snLength := binary.BigEndian.Uint16(headers[12:14])
-	snBytes := payload[snPosition : snPosition+snLength]
-	target.serialNumber = snBytes
-
-	// personal identifier
-	piPosition := binary.BigEndian.Uint16(headers[14:16])
-	piLength := binary.BigEndian.Uint16(headers[16:18])
-	piBytes := payload[piPosition : piPosition+piLength]
-	target.identifier = piBytes
-
-	// certificate
-	certPosition := binary.BigEndian.Uint16(headers[18:20])
-	certLength := binary.BigEndian.Uint16(headers[20:22])
-	certBytes := payload[certPosition : certPosition+certLength]
-	target.certificate = certBytes
-
-	// private key
-	pkPosition := binary.BigEndian.Uint16(headers[22:24])
-	pkLength := binary.BigEndian.Uint16(headers[24:26])
-	pkBytes := payload[pkPosition : pkPosition+pkLength]
-	target.privateKey = pkBytes
-
-	// email
-	emailPosition := binary.BigEndian.Uint16(headers[26:28])
-	emailLength := binary.BigEndian.Uint16(headers[28:30])
-	emailBytes := payload[emailPosition : emailPosition+emailLength]
-	target.email = emailBytes
-
-	// username
-	usernamePosition := binary.BigEndian.Uint16(headers[30:32])
-	usernameLength := binary.BigEndian.Uint16(headers[32:34])
-	usernameBytes := payload[usernamePosition : usernamePosition+usernameLength]
-	target.username = usernameBytes
-
-	// token
-	tokenPosition := binary.BigEndian.Uint16(headers[34:36])
-	tokenLength := binary.BigEndian.Uint16(headers[36:38])
-	tokenBytes := payload[tokenPosition : tokenPosition+tokenLength]
-	target.token = tokenBytes
-
-	// signature
-	signaturePosition := binary.BigEndian.Uint16(headers[38:40])
-	signatureLength := binary.BigEndian.Uint16(headers[40:42])
-	signatureBytes := payload[signaturePosition : signaturePosition+signatureLength]
-	target.signature = signatureBytes
-
-	// root certificate
-	rootCertPosition := binary.BigEndian.Uint16(headers[42:44])
-	rootCertLength := binary.BigEndian.Uint16(headers[44:])
-	rootCertBytes := payload[rootCertPosition : rootCertPosition+rootCertLength]
-	target.rootCertificate = rootCertBytes
+	var extensions []Extension
+	if metaFlags(versionBytes[versionSize:])&flagExtensions != 0 {
+		if extensions, err = parseExtensions(payload[fieldsEnd(headers):]); err != nil {
+			return errors.Join(checksumErr, err)
+		}
+	}
+
+	// fields
+	// files written by older versions leave the password header entry zeroed, resulting in an empty password
+	var (
+		meta       = versionBytes[versionSize:]
+		fields     [fieldCount][]byte
+		compressed = metaCompressed(meta) &^ uint16(metaFlags(meta)&flagsEncrypted)
+	)
+	for id := FieldID(0); id < fieldCount; id++ {
+		at := 2 + 4*int(id)
+		position := int(binary.BigEndian.Uint16(headers[at : at+2]))
+		length := int(binary.BigEndian.Uint16(headers[at+2 : at+4]))
+		// limit the capacity, so appending to a field cannot overwrite the following one
+		fields[id] = payload[position : position+length : position+length]
+
+		// encrypted fields are decompressed after decryption
+		if compressed&(1<<id) != 0 && length > 0 {
+			if fields[id], err = inflate(fields[id], target.maxDecompressedSize()); err != nil {
+				return errors.Join(checksumErr, fmt.Errorf("field %s: %w", id, err))
+			}
+		}
+	}
+	target.fields = fields
+	target.applyMeta(meta)
+	target.extensions = extensions
 
 	target.calculateHeaders()
 
+	return checksumErr
+}
+
+// frame splits a marshalled container into header and payload, verifying the checksum trailer if there is one.
+// In lenient mode, a checksum mismatch is returned as checksumErr instead of err.
+func frame(allBytes []byte, lenient bool) (headers, payload []byte, checksumErr, err error) {
+	if len(allBytes) < int(headerSize) {
+		return nil, nil, nil, fmt.Errorf("byte slice is not large enough")
+	}
+	headers = allBytes[:headerSize]
+	payload = allBytes[headerSize:]
+	if err = checkBounds(headers, len(payload)); err != nil {
+		return nil, nil, nil, err
+	}
+
+	// the checksum trailer follows the payload
+	ch := metaChecksum(payload[int(headers[0])+versionSize : int(headers[0])+int(headers[1])])
+	if ch == ChecksumNone {
+		if err = checkEnd(headers, payload); err != nil {
+			return nil, nil, nil, err
+		}
+		return headers, payload, nil, nil
+	}
+	if ch.digestSize() == 0 {
+		return nil, nil, nil, fmt.Errorf("unsupported checksum %s", ch)
+	}
+	if len(payload) < ch.trailerLen() {
+		return nil, nil, nil, fmt.Errorf("checksum trailer is truncated")
+	}
+	if e := verifyChecksum(allBytes, ch); e != nil {
+		if !lenient {
+			return nil, nil, nil, e
+		}
+		checksumErr = e
+	}
+	payload = payload[:len(payload)-ch.trailerLen()]
+	if err = checkBounds(headers, len(payload)); err != nil {
+		return nil, nil, nil, errors.Join(checksumErr, err)
+	}
+	if err = checkEnd(headers, payload); err != nil {
+		return nil, nil, nil, errors.Join(checksumErr, err)
+	}
+	return headers, payload, checksumErr, nil
+}
+
+// checkEnd makes sure the payload ends with the last field or the extension area. Otherwise, a checksum trailer
+// could be ignored silently, e.g. after the checksum algorithm has been cleared. The bounds have been checked.
+func checkEnd(headers, payload []byte) error {
+	var (
+		end  = fieldsEnd(headers)
+		meta = payload[int(headers[0])+versionSize : int(headers[0])+int(headers[1])]
+	)
+	if metaFlags(meta)&flagExtensions != 0 {
+		if len(payload)-end < extensionAreaHeaderSize {
+			return fmt.Errorf("extension area is truncated")
+		}
+		n := binary.BigEndian.Uint32(payload[end:])
+		if uint64(n) > uint64(len(payload)-end-extensionAreaHeaderSize) {
+			return fmt.Errorf("extension area exceeds payload")
+		}
+		end += extensionAreaHeaderSize + int(n)
+	}
+	if end < len(payload) {
+		return fmt.Errorf("unexpected %d bytes after the payload", len(payload)-end)
+	}
+	return nil
+}
+
+// fieldsEnd returns the position within the payload where the version block or field ending last ends. The
+// extension area follows.
+func fieldsEnd(headers []byte) int {
+	end := int(headers[0]) + int(headers[1])
+	for at := 2; at < int(headerSize); at += 4 {
+		if e := int(binary.BigEndian.Uint16(headers[at:at+2])) + int(binary.BigEndian.Uint16(headers[at+2:at+4])); e > end {
+			end = e
+		}
+	}
+	return end
+}
+
+// checkBounds makes sure every position and length pair in the header points into a payload of the given
+// length, so malformed or truncated input results in an error instead of a panic.
+func checkBounds(headers []byte, payloadLen int) error {
+	if headers[1] < 3 || int(headers[0])+int(headers[1]) > payloadLen {
+		return fmt.Errorf("version block exceeds payload")
+	}
+	for i := 2; i+4 <= int(headerSize); i += 4 {
+		position := int(binary.BigEndian.Uint16(headers[i : i+2]))
+		length := int(binary.BigEndian.Uint16(headers[i+2 : i+4]))
+		if position+length > payloadLen {
+			return fmt.Errorf("field at header offset %d exceeds payload", i)
+		}
+	}
 	return nil
 }
 
+// checkSize makes sure the container can be serialized without truncation: every field length and every
+// field position is stored as unsigned 16 bit integer, so each field and everything in front of the last
+// field have to fit into 65,535 bytes.
+func (c *Container) checkSize() error {
+	fields := c.wireFields()
+	return c.checkWireSize(&fields)
+}
+
+// checkWireSize works like checkSize for the given wire fields
+func (c *Container) checkWireSize(fields *[fieldCount][]byte) error {
+	position := c.versionLen()
+	for id, f := range fields {
+		if len(f) > blockMaxSize {
+			return &ErrFieldTooLarge{Field: FieldID(id), Size: len(f), Limit: blockMaxSize}
+		}
+		if position > blockMaxSize {
+			return fmt.Errorf("payload too large: field positions exceed %d bytes", blockMaxSize)
+		}
+		position += len(f)
+	}
+	return c.checkExtensions()
+}
+
 // calculateHeaders sets the header bytes to correct values corresponding to field offsets and lengths. Will be
 // called just before the *Container is marshalled.
 func (c *Container) calculateHeaders() {
-	header := headerBlock[:]
-
-	var (
-		versionLength     = uint16(3)
-		nonceLength       = uint16(len(c.nonce))
-		tagLength         = uint16(len(c.tag))
-		snLength          = uint16(len(c.serialNumber))
-		piLength          = uint16(len(c.identifier))
-		certificateLength = uint16(len(c.certificate))
-		privateKeyLength  = uint16(len(c.privateKey))
-		emailLength       = uint16(len(c.email))
-		usernameLength    = uint16(len(c.username))
-		tokenLength       = uint16(len(c.token))
-		signatureLength   = uint16(len(c.signature))
-		rootCertLength    = uint16(len(c.rootCertificate))
-	)
+	fields := c.wireFields()
+	c.writeHeaders(&fields)
+}
 
-	var offset uint16 = 0
+// writeHeaders sets the header bytes according to the given wire fields
+func (c *Container) writeHeaders(fields *[fieldCount][]byte) {
+	c.headers = headerBlock
 
 	// version
-	// no need to set any values
-	offset += versionLength
-
-	// nonce
-	binary.BigEndian.PutUint16(header[2:4], offset)
-	binary.BigEndian.PutUint16(header[4:6], nonceLength)
-	offset += nonceLength
-
-	// tag
-	binary.BigEndian.PutUint16(header[6:8], offset)
-	binary.BigEndian.PutUint16(header[8:10], tagLength)
-	offset += tagLength
-
-	// serial number
-	binary.BigEndian.PutUint16(header[10:12], offset)
-	binary.BigEndian.PutUint16(header[12:14], snLength)
-	offset += snLength
-
-	// personal identifier
-	binary.BigEndian.PutUint16(header[14:16], offset)
-	binary.BigEndian.PutUint16(header[16:18], piLength)
-	offset += piLength
-
-	// certificate
-	binary.BigEndian.PutUint16(header[18:20], offset)
-	binary.BigEndian.PutUint16(header[20:22], certificateLength)
-	offset += certificateLength
-
-	// private key
-	binary.BigEndian.PutUint16(header[22:24], offset)
-	binary.BigEndian.PutUint16(header[24:26], privateKeyLength)
-	offset += privateKeyLength
-
-	// email
-	binary.BigEndian.PutUint16(header[26:28], offset)
-	binary.BigEndian.PutUint16(header[28:30], emailLength)
-	offset += emailLength
-
-	// username
-	binary.BigEndian.PutUint16(header[30:32], offset)
-	binary.BigEndian.PutUint16(header[32:34], usernameLength)
-	offset += usernameLength
-
-	// token
-	binary.BigEndian.PutUint16(header[34:36], offset)
-	binary.BigEndian.PutUint16(header[36:38], tokenLength)
-	offset += tokenLength
-
-	// signature
-	binary.BigEndian.PutUint16(header[38:40], offset)
-	binary.BigEndian.PutUint16(header[40:42], signatureLength)
-	offset += signatureLength
-
-	// root certificate
-	binary.BigEndian.PutUint16(header[42:44], offset)
-	binary.BigEndian.PutUint16(header[44:], rootCertLength) // leave upper bound open
-	//offset += rootCertLength // no need to increase this further
-
-	copy(c.headers[:], header)
+	versionLength := c.versionLen()
+	c.headers[1] = byte(versionLength)
+	offset := uint16(versionLength)
+
+	for id, f := range fields {
+		at := 2 + 4*id
+		binary.BigEndian.PutUint16(c.headers[at:at+2], offset)
+		binary.BigEndian.PutUint16(c.headers[at+2:at+4], uint16(len(f)))
+		offset += uint16(len(f))
+	}
 }
 
 // SetRandomNonce generates a 12-byte nonce (mainly for use with AES) and stores it
@@ -626,63 +706,56 @@ func (c *Container) SetRandomNonce() error {
 // All blocks will be encrypted and written back, no data is returned. Requires a key with a length of
 // 16 bytes (AES-128), 24 bytes (AES-192) or 32 bytes (AES-256).
 // The nonce requires a length of 12 bytes. You can use SetRandomNonce() to generate a cryptographically secure nonce.
+// The encrypted fields are recorded in the header flags. If any field is already encrypted, ErrAlreadyEncrypted
+// is returned and nothing is changed.
 func (c *Container) EncryptEverything(nonce []byte, key []byte) error {
-	sn, err := c.EncryptSerialNumber(nonce, key)
-	if err != nil {
-		return err
-	}
-
-	id, err := c.EncryptIdentifier(nonce, key)
-	if err != nil {
-		return err
+	for _, id := range encryptedFields {
+		if c.isEncrypted(id) {
+			return fmt.Errorf("field %s: %w", id, ErrAlreadyEncrypted)
+		}
+	}
+	for _, e := range c.extensions {
+		if e.Encrypt && e.Encrypted {
+			return fmt.Errorf("extension %s: %w", e.Key, ErrAlreadyEncrypted)
+		}
+	}
+
+	// compressed fields are compressed before encryption
+	var encrypted [fieldCount][]byte
+	for _, id := range encryptedFields {
+		b, err := encryptAes(key, c.compressedValue(id), nonce)
+		if err != nil {
+			return err
+		}
+		encrypted[id] = b
+	}
+	encryptedExtensions := make([][]byte, len(c.extensions))
+	for i, e := range c.extensions {
+		if !e.Encrypt {
+			continue
+		}
+		b, err := encryptAes(key, e.Value, nonce)
+		if err != nil {
+			return err
+		}
+		encryptedExtensions[i] = b
 	}
 
-	cert, err := c.EncryptCertificate(nonce, key)
-	if err != nil {
-		return err
-	}
-
-	pk, err := c.EncryptPrivateKey(nonce, key)
-	if err != nil {
-		return err
-	}
-
-	email, err := c.EncryptEmail(nonce, key)
-	if err != nil {
-		return err
-	}
-
-	username, err := c.EncryptUsername(nonce, key)
-	if err != nil {
-		return err
-	}
-
-	token, err := c.EncryptToken(nonce, key)
-	if err != nil {
-		return err
-	}
-
-	sig, err := c.EncryptSignature(nonce, key)
-	if err != nil {
-		return err
+	// everything or nothing
+	// set the values only if no error occurs, absent fields stay absent
+	for _, id := range encryptedFields {
+		if c.fields[id] != nil {
+			c.fields[id] = encrypted[id]
+			c.setFlag(1<<id, true)
+		}
 	}
-
-	rootCert, err := c.EncryptRootCertificate(nonce, key)
-	if err != nil {
-		return err
+	for i := range c.extensions {
+		if c.extensions[i].Encrypt {
+			c.extensions[i].Value = encryptedExtensions[i]
+			c.extensions[i].Encrypted = true
+		}
 	}
-
-	// everything or nothing
-	// set the values only if no error occurs
-	c.serialNumber = sn
-	c.identifier = id
-	c.certificate = cert
-	c.privateKey = pk
-	c.email = email
-	c.username = username
-	c.token = token
-	c.signature = sig
-	c.rootCertificate = rootCert
+	c.setCipher(CipherAESGCM)
 
 	c.calculateHeaders()
 
@@ -691,108 +764,101 @@ func (c *Container) EncryptEverything(nonce []byte, key []byte) error {
 
 // EncryptSerialNumber encrypts and returns the serial number
 func (c *Container) EncryptSerialNumber(nonce []byte, key []byte) ([]byte, error) {
-	return encryptAes(key, c.serialNumber, nonce)
+	return c.EncryptField(FieldSerialNumber, nonce, key)
 }
 
 // EncryptIdentifier encrypts and returns the identifier
 func (c *Container) EncryptIdentifier(nonce []byte, key []byte) ([]byte, error) {
-	return encryptAes(key, c.identifier, nonce)
+	return c.EncryptField(FieldIdentifier, nonce, key)
 }
 
 // EncryptCertificate encrypts and returns the certificate
 func (c *Container) EncryptCertificate(nonce []byte, key []byte) ([]byte, error) {
-	return encryptAes(key, c.certificate, nonce)
+	return c.EncryptField(FieldCertificate, nonce, key)
 }
 
 // EncryptPrivateKey encrypts and returns the private key
 func (c *Container) EncryptPrivateKey(nonce []byte, key []byte) ([]byte, error) {
-	return encryptAes(key, c.privateKey, nonce)
+	return c.EncryptField(FieldPrivateKey, nonce, key)
 }
 
 // EncryptEmail encrypts and returns the email address
 func (c *Container) EncryptEmail(nonce []byte, key []byte) ([]byte, error) {
-	return encryptAes(key, c.email, nonce)
+	return c.EncryptField(FieldEmail, nonce, key)
 }
 
 // EncryptUsername encrypts and returns the username
 func (c *Container) EncryptUsername(nonce []byte, key []byte) ([]byte, error) {
-	return encryptAes(key, c.username, nonce)
+	return c.EncryptField(FieldUsername, nonce, key)
 }
 
 // EncryptToken encrypts and returns the token
 func (c *Container) EncryptToken(nonce []byte, key []byte) ([]byte, error) {
-	return encryptAes(key, c.token, nonce)
+	return c.EncryptField(FieldToken, nonce, key)
 }
 
 // EncryptSignature encrypts and returns the signature
 func (c *Container) EncryptSignature(nonce []byte, key []byte) ([]byte, error) {
-	return encryptAes(key, c.signature, nonce)
+	return c.EncryptField(FieldSignature, nonce, key)
 }
 
-// EncryptRootCertificate encrypts and returns the signature
+// EncryptRootCertificate encrypts and returns the root certificate
 func (c *Container) EncryptRootCertificate(nonce []byte, key []byte) ([]byte, error) {
-	return encryptAes(key, c.rootCertificate, nonce)
+	return c.EncryptField(FieldRootCertificate, nonce, key)
+}
+
+// EncryptPassword encrypts and returns the password
+func (c *Container) EncryptPassword(nonce []byte, key []byte) ([]byte, error) {
+	return c.EncryptField(FieldPassword, nonce, key)
 }
 
 // DecryptEverything is the obvious counterpart to EncryptEverything. It performs the decryption in place, using
-// either AES-128, AES-192 or AES-256, depending on key length.
+// either AES-128, AES-192 or AES-256, depending on key length. The header flags are cleared afterwards.
 func (c *Container) DecryptEverything(nonce []byte, key []byte) error {
-	sn, err := c.DecryptSerialNumber(nonce, key)
-	if err != nil {
-		return err
-	}
-
-	id, err := c.DecryptIdentifier(nonce, key)
-	if err != nil {
-		return err
-	}
-
-	cert, err := c.DecryptCertificate(nonce, key)
-	if err != nil {
-		return err
-	}
-
-	pk, err := c.DecryptPrivateKey(nonce, key)
-	if err != nil {
-		return err
+	if ci := c.Cipher(); ci != CipherNone && ci != CipherAESGCM {
+		return fmt.Errorf("unsupported cipher %s", ci)
+	}
+
+	var decrypted [fieldCount][]byte
+	for _, id := range encryptedFields {
+		b, err := c.DecryptField(id, nonce, key)
+		if err != nil {
+			return err
+		}
+		if c.isEncrypted(id) && c.isCompressed(id) && c.fields[id] != nil {
+			if b, err = inflate(b, c.maxDecompressedSize()); err != nil {
+				return fmt.Errorf("field %s: %w", id, err)
+			}
+		}
+		decrypted[id] = b
+	}
+	decryptedExtensions := make([][]byte, len(c.extensions))
+	for i, e := range c.extensions {
+		if !e.Encrypted {
+			continue
+		}
+		b, err := decryptAes(key, e.Value, nonce)
+		if err != nil {
+			return fmt.Errorf("extension %s: %w", e.Key, err)
+		}
+		decryptedExtensions[i] = b
 	}
 
-	email, err := c.DecryptEmail(nonce, key)
-	if err != nil {
-		return err
-	}
-
-	username, err := c.DecryptUsername(nonce, key)
-	if err != nil {
-		return err
-	}
-
-	token, err := c.DecryptToken(nonce, key)
-	if err != nil {
-		return err
-	}
-
-	sig, err := c.DecryptSignature(nonce, key)
-	if err != nil {
-		return err
+	// everything or nothing
+	for _, id := range encryptedFields {
+		if c.fields[id] != nil {
+			c.fields[id] = decrypted[id]
+		}
 	}
-
-	rootCert, err := c.DecryptRootCertificate(nonce, key)
-	if err != nil {
-		return err
+	for i := range c.extensions {
+		if c.extensions[i].Encrypted {
+			c.extensions[i].Value = decryptedExtensions[i]
+			c.extensions[i].Encrypted = false
+		}
 	}
-
-	// everything or nothing
-	// TODO: use setters
-	c.serialNumber = sn
-	c.identifier = id
-	c.certificate = cert
-	c.privateKey = pk
-	c.email = email
-	c.username = username
-	c.token = token
-	c.signature = sig
-	c.rootCertificate = rootCert
+	c.flags &^= flagsEncrypted
+	c.setCipher(CipherNone)
+	c.SetKDF(KDFNone)
 
 	c.calculateHeaders()
 
@@ -801,65 +867,52 @@ func (c *Container) DecryptEverything(nonce []byte, key []byte) error {
 
 // DecryptSerialNumber decrypts and returns the serial number
 func (c *Container) DecryptSerialNumber(nonce []byte, key []byte) ([]byte, error) {
-	return decryptAes(key, c.serialNumber, nonce)
+	return c.DecryptField(FieldSerialNumber, nonce, key)
 }
 
 // DecryptIdentifier decrypts and returns the identifier
 func (c *Container) DecryptIdentifier(nonce []byte, key []byte) ([]byte, error) {
-	return decryptAes(key, c.identifier, nonce)
+	return c.DecryptField(FieldIdentifier, nonce, key)
 }
 
 // DecryptCertificate decrypts and returns the certificate
 func (c *Container) DecryptCertificate(nonce []byte, key []byte) ([]byte, error) {
-	return decryptAes(key, c.certificate, nonce)
+	return c.DecryptField(FieldCertificate, nonce, key)
 }
 
 // DecryptPrivateKey decrypts and returns the private key
 func (c *Container) DecryptPrivateKey(nonce []byte, key []byte) ([]byte, error) {
-	return decryptAes(key, c.privateKey, nonce)
+	return c.DecryptField(FieldPrivateKey, nonce, key)
 }
 
 // DecryptEmail decrypts and returns the email address
 func (c *Container) DecryptEmail(nonce []byte, key []byte) ([]byte, error) {
-	return decryptAes(key, c.email, nonce)
+	return c.DecryptField(FieldEmail, nonce, key)
 }
 
 // DecryptUsername decrypts and returns the username
 func (c *Container) DecryptUsername(nonce []byte, key []byte) ([]byte, error) {
-	return decryptAes(key, c.username, nonce)
+	return c.DecryptField(FieldUsername, nonce, key)
 }
 
 // DecryptToken decrypts and returns the token
 func (c *Container) DecryptToken(nonce []byte, key []byte) ([]byte, error) {
-	return decryptAes(key, c.token, nonce)
+	return c.DecryptField(FieldToken, nonce, key)
 }
 
 // DecryptSignature decrypts and returns the signature
 func (c *Container) DecryptSignature(nonce []byte, key []byte) ([]byte, error) {
-	return decryptAes(key, c.signature, nonce)
+	return c.DecryptField(FieldSignature, nonce, key)
 }
 
 // DecryptRootCertificate decrypts and returns the root certificate
 func (c *Container) DecryptRootCertificate(nonce []byte, key []byte) ([]byte, error) {
-	return decryptAes(key, c.rootCertificate, nonce)
+	return c.DecryptField(FieldRootCertificate, nonce, key)
 }
 
-// Dump just writes all field contents into an io.Writer
-func (c *Container) Dump(w io.Writer) {
-	if w == nil {
-		return
-	}
-
-	_, _ = fmt.Fprintf(w, "Semantic Version: %s\n", c.GetSemVer())
-	_, _ = fmt.Fprintf(w, "Serial number: %s\n", c.GetSerialNumber())
-	_, _ = fmt.Fprintf(w, "identifier: %s\n", c.GetIdentifier())
-	_, _ = fmt.Fprintf(w, "certificate: %s\n", c.GetCertificate())
-	_, _ = fmt.Fprintf(w, "Private Key: %s\n", c.GetPrivateKey())
-	_, _ = fmt.Fprintf(w, "email: %s\n", c.GetEmail())
-	_, _ = fmt.Fprintf(w, "username: %s\n", c.GetUsername())
-	_, _ = fmt.Fprintf(w, "token: %s\n", c.GetToken())
-	_, _ = fmt.Fprintf(w, "signature: %s\n", c.GetSignature())
-	_, _ = fmt.Fprintf(w, "Root certificate: %s\n", c.GetRootCertificate())
+// DecryptPassword decrypts and returns the password
+func (c *Container) DecryptPassword(nonce []byte, key []byte) ([]byte, error) {
+	return c.DecryptField(FieldPassword, nonce, key)
 }
 
 func encryptAes(key []byte, s []byte, nonce []byte) ([]byte, error) {
@@ -2,6 +2,7 @@ package eraf
 
 import (
 	"bytes"
+	"encoding/hex"
 	"io"
 	"io/ioutil"
 	"os"
@@ -653,6 +654,223 @@ func Test_Container_MarshalBytes(t *testing.T) {
 	}
 }
 
+func Test_Container_MarshalAppend(t *testing.T) {
+	c := New().SetEmail([]byte{}).SetToken([]byte("token")).SetChecksum(ChecksumSHA256)
+	if err := c.SetExtension(Extension{Key: StringKey("region"), Value: []byte("eu")}); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	c.SetSigned(true)
+	expected := c.MarshalBytes()
+	if len(expected) != c.Len() || cap(expected) != c.Len() {
+		t.Errorf("expected length and capacity of %d, got %d and %d", c.Len(), len(expected), cap(expected))
+	}
+
+	prefix := []byte("prefix")
+	b := c.MarshalAppend(prefix)
+	if string(b[:len(prefix)]) != "prefix" || !bytes.Equal(b[len(prefix):], expected) {
+		t.Errorf("expected container to be appended to dst")
+	}
+
+	result := &Container{}
+	if err := UnmarshalBytes(b[len(prefix):], result); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if string(result.GetToken()) != "token" || !result.Has(FieldEmail) {
+		t.Errorf("expected fields to survive the round trip")
+	}
+
+	buf := make([]byte, 0, c.Len())
+	allocs := testing.AllocsPerRun(100, func() {
+		buf = c.MarshalAppend(buf[:0])
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations when reusing the buffer, got %.1f", allocs)
+	}
+}
+
+func Test_Encoder(t *testing.T) {
+	var (
+		buf bytes.Buffer
+		enc = NewEncoder(&buf)
+		a   = New().SetUsername([]byte("a"))
+		b   = New().SetUsername([]byte("bb")).SetChecksum(ChecksumCRC32C)
+	)
+	for _, c := range []*Container{a, b, a} {
+		if err := enc.Encode(c); err != nil {
+			t.Fatalf("expected no error, got %s", err.Error())
+		}
+	}
+	expected := append(append(a.MarshalBytes(), b.MarshalBytes()...), a.MarshalBytes()...)
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Errorf("expected the concatenated containers")
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = enc.Encode(b)
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations in steady state, got %.1f", allocs)
+	}
+
+	big := New().SetCertificate(make([]byte, blockMaxSize)).SetEmail([]byte("a"))
+	if err := enc.Encode(big); err == nil {
+		t.Errorf("expected error for field positions exceeding the header")
+	}
+}
+
+func Test_UnmarshalBytes_AllFields(t *testing.T) {
+	var (
+		c = New().SetVersionMajor(1).SetVersionMinor(2).SetVersionPatch(3).
+			SetNonce([]byte("nonce")).SetTag([]byte("tag")).SetSerialNumber([]byte("sn")).
+			SetIdentifier([]byte("id")).SetCertificate([]byte("cert")).SetPrivateKey([]byte("key")).
+			SetEmail([]byte("mail")).SetUsername([]byte("user")).SetToken([]byte("token")).
+			SetSignature([]byte("sig")).SetRootCertificate([]byte("root")).SetPassword([]byte("pass"))
+		target = New()
+	)
+
+	if err := UnmarshalBytes(c.MarshalBytes(), target); err != nil {
+		t.Fatalf("could not unmarshal: %s", err.Error())
+	}
+	if !bytes.Equal(c.MarshalBytes(), target.MarshalBytes()) {
+		t.Errorf("expected identical bytes after round trip")
+	}
+	if string(target.GetRootCertificate()) != "root" || string(target.GetPassword()) != "pass" {
+		t.Errorf("expected root certificate 'root' and password 'pass', got '%s' and '%s'",
+			target.GetRootCertificate(), target.GetPassword())
+	}
+}
+
+func Test_UnmarshalBytes_Legacy(t *testing.T) {
+	// written by the first release, which did not write the root certificate and the password
+	legacy, _ := hex.DecodeString("00030003000500080003000b0002000d0002000f00040013000300160004001a0004001e00050023" +
+		"000300260000000000000102036e6f6e6365746167736e6964636572746b65796d61696c75736572746f6b656e736967")
+
+	c := New()
+	if err := UnmarshalBytes(legacy, c); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if c.GetSemVer() != "1.2.3" {
+		t.Errorf("expected version 1.2.3, got %s", c.GetSemVer())
+	}
+	expected := [fieldCount]string{"nonce", "tag", "sn", "id", "cert", "key", "mail", "user", "token", "sig", "", ""}
+	for id, e := range expected {
+		if got := string(c.Get(FieldID(id))); got != e {
+			t.Errorf("expected %s '%s', got '%s'", FieldID(id), e, got)
+		}
+	}
+	if c.Has(FieldRootCertificate) || c.Has(FieldPassword) {
+		t.Errorf("expected root certificate and password to be absent")
+	}
+}
+
+func Test_UnmarshalBytes_Copy(t *testing.T) {
+	c := New().SetEmail([]byte("someone@example.com")).SetUsername([]byte("someone"))
+	if err := c.SetExtension(Extension{Key: StringKey("tenant"), Value: []byte("acme")}); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+
+	b := c.MarshalBytes()
+	copied, aliased := New(), New()
+	if err := UnmarshalBytes(b, copied); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if err := UnmarshalNoCopy(b, aliased); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	for i := range b {
+		b[i] = 'x'
+	}
+
+	tenant, _ := copied.GetExtension(StringKey("tenant"))
+	if string(copied.GetEmail()) != "someone@example.com" || string(tenant) != "acme" {
+		t.Errorf("expected UnmarshalBytes to be isolated from the input, got email '%s'", copied.GetEmail())
+	}
+	if string(aliased.GetEmail()) == "someone@example.com" {
+		t.Errorf("expected UnmarshalNoCopy to refer to the input")
+	}
+
+	// appending to a field must not overwrite the following field
+	_ = append(copied.GetEmail(), "!!!"...)
+	if string(copied.GetUsername()) != "someone" {
+		t.Errorf("expected username to be unaffected, got '%s'", copied.GetUsername())
+	}
+}
+
+func Test_UnmarshalBytes_Malformed(t *testing.T) {
+	b := New().SetEmail([]byte("my@cool-domain.com")).MarshalBytes()
+
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{name: "too short", input: b[:10]},
+		{name: "truncated payload", input: b[:len(b)-5]},
+		{name: "bad version length", input: func() []byte {
+			c := append([]byte{}, b...)
+			c[1] = 200
+			return c
+		}()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := UnmarshalBytes(tt.input, New()); err == nil {
+				t.Errorf("expected error for malformed input")
+			}
+		})
+	}
+}
+
+func Test_UnmarshalBytes_LargeOffsets(t *testing.T) {
+	// the version block ends at 300, beyond the range of a byte
+	version := make([]byte, int(headerSize)+300)
+	version[0], version[1] = 200, 100
+	version[int(headerSize)+200] = 7
+
+	// the nonce ends at 66000, beyond the range of an uint16
+	nonce := make([]byte, int(headerSize)+66000)
+	nonce[1] = versionSize
+	nonce[2], nonce[3], nonce[4], nonce[5] = 0xfd, 0xe8, 0x03, 0xe8
+	nonce[int(headerSize)+65000] = 42
+
+	for name, tt := range map[string]struct {
+		input []byte
+		check func(c *Container) bool
+	}{
+		"version": {version, func(c *Container) bool { return c.GetVersionMajor() == 7 }},
+		"nonce":   {nonce, func(c *Container) bool { return len(c.GetNonce()) == 1000 && c.GetNonce()[0] == 42 }},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := New()
+			if err := UnmarshalBytes(tt.input, c); err != nil {
+				t.Fatalf("expected no error, got %s", err.Error())
+			}
+			if !tt.check(c) {
+				t.Errorf("expected the values at the given positions")
+			}
+			if _, err := NewView(tt.input); err != nil {
+				t.Errorf("expected no error from NewView, got %s", err.Error())
+			}
+		})
+	}
+}
+
+func Fuzz_UnmarshalBytes(f *testing.F) {
+	f.Add(New().SetEmail([]byte("my@cool-domain.com")).SetChecksum(ChecksumCRC32C).MarshalBytes())
+	f.Add(make([]byte, int(headerSize)+10))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		c := New()
+		if UnmarshalBytes(b, c) == nil {
+			_ = c.MarshalBytes()
+		}
+		if v, err := NewView(b); err == nil {
+			for id := FieldID(0); id < fieldCount; id++ {
+				_ = v.Get(id)
+			}
+			_ = v.SemVer()
+		}
+	})
+}
+
 /**
 Benchmark tests
 */
@@ -760,6 +978,34 @@ func Benchmark_Marshal(b *testing.B) {
 	}
 }
 
+func Benchmark_MarshalAppend(b *testing.B) {
+	var (
+		c   = New().SetEmail([]byte("my@nice-domain.local")).SetIdentifier([]byte{1, 2, 3, 4, 5, 6, 7, 78, 8, 9, 9})
+		buf []byte
+	)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		buf = c.MarshalAppend(buf[:0])
+	}
+}
+
+func Benchmark_Encoder(b *testing.B) {
+	var (
+		c   = New().SetEmail([]byte("my@nice-domain.local")).SetIdentifier([]byte{1, 2, 3, 4, 5, 6, 7, 78, 8, 9, 9})
+		enc = NewEncoder(io.Discard)
+	)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := enc.Encode(c); err != nil {
+			b.Fatal(err.Error())
+		}
+	}
+}
+
 func Benchmark_calculateHeaders(b *testing.B) {
 	c := New().SetEmail([]byte("cool@mailer.org")).SetPassword([]byte{1, 2, 3, 4, 5, 6, 7, 91})
 	b.ResetTimer()
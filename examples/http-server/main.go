@@ -4,12 +4,15 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 
 	eraf "github.com/KaiserWerk/ERAF-Go-SDK"
 )
 
 var (
 	aesKey = []byte("3d9p8MV0eFe2JeXe6YnD8RNjQ4GdbtNS")
+	// remembers received nonces for an hour, so captured containers cannot be replayed
+	replayGuard = eraf.NewReplayGuard(time.Hour)
 )
 
 func main() {
@@ -30,8 +33,13 @@ func handler(w http.ResponseWriter, r *http.Request) {
 		defer r.Body.Close()
 		fmt.Println("unmarshal ok")
 
-		fmt.Println("email:", string(container.GetEmail()))
-
+		// the encryption flags are not authenticated, so plaintext fields must be rejected instead of being trusted
+		if !fullyEncrypted(container) {
+			fmt.Println("container is not encrypted")
+			w.WriteHeader(400)
+			return
+		}
+		fmt.Println("encrypted fields:", container.EncryptedFields())
 		err = container.DecryptEverything(container.GetNonce(), aesKey)
 		if err != nil {
 			fmt.Println("could not decrypt:", err.Error())
@@ -40,9 +48,32 @@ func handler(w http.ResponseWriter, r *http.Request) {
 		}
 		fmt.Println("decrypt ok")
 
+		// the nonce is only recorded after decryption has authenticated the container, so forged containers
+		// cannot burn the nonces of legitimate ones
+		err = container.Validate(eraf.WithReplayGuard(replayGuard))
+		if err != nil {
+			fmt.Println("invalid container:", err.Error())
+			w.WriteHeader(400)
+			return
+		}
+
 		fmt.Println("email", string(container.GetEmail()))
 		return
 	}
 
 	io.WriteString(w, "hello!")
 }
+
+// fullyEncrypted reports whether every present field, except nonce and tag, is encrypted
+func fullyEncrypted(c *eraf.Container) bool {
+	encrypted := make(map[eraf.FieldID]bool)
+	for _, id := range c.EncryptedFields() {
+		encrypted[id] = true
+	}
+	for _, f := range c.Fields() {
+		if f.Value != nil && f.ID != eraf.FieldNonce && f.ID != eraf.FieldTag && !encrypted[f.ID] {
+			return false
+		}
+	}
+	return c.IsEncrypted()
+}
@@ -0,0 +1,388 @@
+package eraf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+)
+
+// The extension area follows the fixed fields and is only written if the container has extensions, which is
+// recorded in the header flags. Older versions of this SDK ignore it, since they address all fields by their
+// absolute position.
+//
+//	4 bytes: length of all entries
+//	per entry:
+//	  1 byte:  flags, see below
+//	  key:     4 bytes for numeric keys, 1 byte length followed by the name for string keys
+//	  2 bytes: value length, followed by the value
+//
+// Flag bits of an entry which are unknown to this version are preserved.
+const (
+	extensionAreaHeaderSize = 4
+
+	flagExtensions uint32 = 1 << 14
+
+	extNumeric   byte = 1 << 0
+	extEncrypt   byte = 1 << 1
+	extSign      byte = 1 << 2
+	extEncrypted byte = 1 << 3
+	extKnown          = extNumeric | extEncrypt | extSign | extEncrypted
+
+	extensionKeyMaxSize = 255
+	// number of extensions checked for duplicate keys without allocating, see checkExtensionKeys
+	extensionsPairwiseMax = 32
+)
+
+// ExtensionKey identifies an extension, either by name or by number. Use StringKey or NumericKey to create one.
+type ExtensionKey struct {
+	name    string
+	number  uint32
+	numeric bool
+}
+
+// StringKey returns the key of an extension identified by name, e.g. tenant. Names are limited to 255 bytes.
+func StringKey(name string) ExtensionKey {
+	return ExtensionKey{name: name}
+}
+
+// NumericKey returns the key of an extension identified by number
+func NumericKey(n uint32) ExtensionKey {
+	return ExtensionKey{number: n, numeric: true}
+}
+
+// IsNumeric reports whether the key has been created using NumericKey
+func (k ExtensionKey) IsNumeric() bool {
+	return k.numeric
+}
+
+// Name returns the name of a string key, or an empty string for numeric keys
+func (k ExtensionKey) Name() string {
+	return k.name
+}
+
+// Number returns the number of a numeric key, or 0 for string keys
+func (k ExtensionKey) Number() uint32 {
+	return k.number
+}
+
+// String returns the name of a string key or the number of a numeric key prefixed with #, e.g. #42
+func (k ExtensionKey) String() string {
+	if k.numeric {
+		return "#" + strconv.FormatUint(uint64(k.number), 10)
+	}
+	return k.name
+}
+
+func (k ExtensionKey) valid() error {
+	if !k.numeric && (len(k.name) == 0 || len(k.name) > extensionKeyMaxSize) {
+		return fmt.Errorf("extension name must have between 1 and %d bytes", extensionKeyMaxSize)
+	}
+	return nil
+}
+
+// Extension is an arbitrary named or numbered value stored in the extension area of a container
+type Extension struct {
+	Key   ExtensionKey
+	Value []byte
+	// Encrypt includes the extension in EncryptEverything and DecryptEverything
+	Encrypt bool
+	// Sign includes the extension in CanonicalBytes, so it is covered by the signature of the container
+	Sign bool
+	// Encrypted states whether Value is currently encrypted. It is maintained by EncryptEverything and
+	// DecryptEverything.
+	Encrypted bool
+
+	// unknown flag bits, preserved on re-marshal
+	unknown byte
+}
+
+// SetExtension adds the extension to the container or replaces the one with the same key, keeping its position.
+// An extension with a nil value is removed. Values are limited to 65,535 bytes.
+func (c *Container) SetExtension(e Extension) error {
+	if err := e.Key.valid(); err != nil {
+		return err
+	}
+	if len(e.Value) > blockMaxSize {
+		return fmt.Errorf("extension %s has %d bytes, exceeding the maximum of %d bytes", e.Key, len(e.Value), blockMaxSize)
+	}
+
+	for i := range c.extensions {
+		if c.extensions[i].Key != e.Key {
+			continue
+		}
+		if e.Value == nil {
+			c.extensions = append(c.extensions[:i], c.extensions[i+1:]...)
+		} else {
+			e.Value = c.own(e.Value)
+			c.extensions[i] = e
+		}
+		return nil
+	}
+	if e.Value != nil {
+		e.Value = c.own(e.Value)
+		c.extensions = append(c.extensions, e)
+	}
+	return nil
+}
+
+// GetExtension returns the value of the extension with the given key and whether it exists
+func (c *Container) GetExtension(key ExtensionKey) ([]byte, bool) {
+	if e := c.extension(key); e != nil {
+		return c.own(e.Value), true
+	}
+	return nil, false
+}
+
+// extension returns the extension with the given key, nil if there is none
+func (c *Container) extension(key ExtensionKey) *Extension {
+	for i := range c.extensions {
+		if c.extensions[i].Key == key {
+			return &c.extensions[i]
+		}
+	}
+	return nil
+}
+
+// Extensions returns all extensions in the order they are stored, including those unknown to the application
+func (c *Container) Extensions() []Extension {
+	if len(c.extensions) == 0 {
+		return nil
+	}
+	return append([]Extension(nil), c.extensions...)
+}
+
+func (e *Extension) flags() byte {
+	f := e.unknown
+	if e.Key.numeric {
+		f |= extNumeric
+	}
+	if e.Encrypt {
+		f |= extEncrypt
+	}
+	if e.Sign {
+		f |= extSign
+	}
+	if e.Encrypted {
+		f |= extEncrypted
+	}
+	return f
+}
+
+func (e *Extension) size() int {
+	n := 1 + 2 + len(e.Value)
+	if e.Key.numeric {
+		return n + 4
+	}
+	return n + 1 + len(e.Key.name)
+}
+
+// extensionsLen returns the length of the extension area, 0 if there are no extensions
+func (c *Container) extensionsLen() int {
+	if len(c.extensions) == 0 {
+		return 0
+	}
+	n := extensionAreaHeaderSize
+	for i := range c.extensions {
+		n += c.extensions[i].size()
+	}
+	return n
+}
+
+// appendExtensions appends the extension area to b
+func (c *Container) appendExtensions(b []byte) []byte {
+	if len(c.extensions) == 0 {
+		return b
+	}
+	b = binary.BigEndian.AppendUint32(b, uint32(c.extensionsLen()-extensionAreaHeaderSize))
+	for i := range c.extensions {
+		e := &c.extensions[i]
+		b = append(b, e.flags())
+		if e.Key.numeric {
+			b = binary.BigEndian.AppendUint32(b, e.Key.number)
+		} else {
+			b = append(b, byte(len(e.Key.name)))
+			b = append(b, e.Key.name...)
+		}
+		b = binary.BigEndian.AppendUint16(b, uint16(len(e.Value)))
+		b = append(b, e.Value...)
+	}
+	return b
+}
+
+// checkExtensions makes sure the extensions can be serialized, e.g. after encryption has enlarged their values
+func (c *Container) checkExtensions() error {
+	for _, e := range c.extensions {
+		if len(e.Value) > blockMaxSize {
+			return fmt.Errorf("extension %s has %d bytes, exceeding the maximum of %d bytes", e.Key, len(e.Value), blockMaxSize)
+		}
+	}
+	return nil
+}
+
+// marshalExtensions returns the extension area as a whole, nil if there are no extensions. It is used by the
+// encodings other than the binary format, which carry the extension area as an opaque byte string.
+func (c *Container) marshalExtensions() []byte {
+	if len(c.extensions) == 0 {
+		return nil
+	}
+	return c.appendExtensions(make([]byte, 0, c.extensionsLen()))
+}
+
+// unmarshalExtensions is the counterpart to marshalExtensions. Unlike parseExtensions, trailing data is rejected.
+// The values refer to b.
+func unmarshalExtensions(b []byte) ([]Extension, error) {
+	if b == nil {
+		return nil, nil
+	}
+	extensions, err := parseExtensions(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != extensionAreaHeaderSize+int(binary.BigEndian.Uint32(b)) {
+		return nil, fmt.Errorf("trailing data after extension area")
+	}
+	return extensions, nil
+}
+
+// parseExtensions parses the extension area at the start of b
+func parseExtensions(b []byte) ([]Extension, error) {
+	entries, err := extensionEntries(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		extensions []Extension
+		seen       = make(map[ExtensionKey]bool)
+	)
+	for len(entries) > 0 {
+		var raw rawExtension
+		if raw, entries, err = nextExtension(entries); err != nil {
+			return nil, err
+		}
+		// a second value for the same key could be read by some parsers and ignored by others
+		key := raw.extensionKey()
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate extension %s", key)
+		}
+		seen[key] = true
+		extensions = append(extensions, Extension{
+			Key:       key,
+			Value:     raw.value,
+			Encrypt:   raw.flags&extEncrypt != 0,
+			Sign:      raw.flags&extSign != 0,
+			Encrypted: raw.flags&extEncrypted != 0,
+			unknown:   raw.flags &^ extKnown,
+		})
+	}
+	return extensions, nil
+}
+
+// rawExtension is an entry of the extension area as it is stored
+type rawExtension struct {
+	flags byte
+	// 4 bytes for numeric keys, the name for string keys
+	key   []byte
+	value []byte
+}
+
+func (raw rawExtension) extensionKey() ExtensionKey {
+	if raw.flags&extNumeric != 0 {
+		return NumericKey(binary.BigEndian.Uint32(raw.key))
+	}
+	return StringKey(string(raw.key))
+}
+
+// matches reports whether the entry has the given key without allocating
+func (raw rawExtension) matches(key ExtensionKey) bool {
+	if raw.flags&extNumeric != 0 {
+		return key.numeric && binary.BigEndian.Uint32(raw.key) == key.number
+	}
+	return !key.numeric && string(raw.key) == key.name
+}
+
+// sameKey reports whether both entries have the same key without allocating
+func (raw rawExtension) sameKey(other rawExtension) bool {
+	return raw.flags&extNumeric == other.flags&extNumeric && bytes.Equal(raw.key, other.key)
+}
+
+// checkExtensionKeys rejects duplicate keys among entries which have been validated by nextExtension already.
+// The first extensionsPairwiseMax entries are compared pairwise, so the common case does not allocate, while
+// a map keeps the check linear for larger extension areas.
+func checkExtensionKeys(entries []byte) error {
+	var (
+		first [extensionsPairwiseMax]rawExtension
+		n     int
+		seen  map[ExtensionKey]bool
+	)
+	for len(entries) > 0 {
+		var raw rawExtension
+		raw, entries, _ = nextExtension(entries)
+		if n < len(first) {
+			for _, other := range first[:n] {
+				if raw.sameKey(other) {
+					return fmt.Errorf("duplicate extension %s", raw.extensionKey())
+				}
+			}
+			first[n] = raw
+			n++
+			continue
+		}
+		if seen == nil {
+			seen = make(map[ExtensionKey]bool)
+			for _, other := range first {
+				seen[other.extensionKey()] = true
+			}
+		}
+		key := raw.extensionKey()
+		if seen[key] {
+			return fmt.Errorf("duplicate extension %s", key)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// extensionEntries returns the entries of the extension area at the start of b
+func extensionEntries(b []byte) ([]byte, error) {
+	if len(b) < extensionAreaHeaderSize {
+		return nil, fmt.Errorf("extension area is truncated")
+	}
+	n := binary.BigEndian.Uint32(b)
+	if uint64(n) > uint64(len(b)-extensionAreaHeaderSize) {
+		return nil, fmt.Errorf("extension area exceeds payload")
+	}
+	return b[extensionAreaHeaderSize : extensionAreaHeaderSize+int(n)], nil
+}
+
+// nextExtension parses the first entry of b and returns it along with the remaining entries
+func nextExtension(b []byte) (rawExtension, []byte, error) {
+	var raw rawExtension
+	raw.flags = b[0]
+	b = b[1:]
+
+	if raw.flags&extNumeric != 0 {
+		if len(b) < 4 {
+			return raw, nil, fmt.Errorf("extension key is truncated")
+		}
+		raw.key = b[:4]
+		b = b[4:]
+	} else {
+		if len(b) < 1 || len(b) < 1+int(b[0]) {
+			return raw, nil, fmt.Errorf("extension key is truncated")
+		}
+		if b[0] == 0 {
+			return raw, nil, fmt.Errorf("extension name must have between 1 and %d bytes", extensionKeyMaxSize)
+		}
+		raw.key = b[1 : 1+int(b[0])]
+		b = b[1+int(b[0]):]
+	}
+
+	if len(b) < 2 || len(b) < 2+int(binary.BigEndian.Uint16(b)) {
+		return raw, nil, fmt.Errorf("extension %s is truncated", raw.extensionKey())
+	}
+	l := int(binary.BigEndian.Uint16(b))
+	raw.value = b[2 : 2+l : 2+l]
+	return raw, b[2+l:], nil
+}
@@ -0,0 +1,207 @@
+package eraf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func Test_Container_Extensions(t *testing.T) {
+	c := New().SetEmail([]byte("someone@example.com"))
+	tests := []Extension{
+		{Key: StringKey("tenant"), Value: []byte("acme")},
+		{Key: NumericKey(42), Value: []byte{1, 2, 3}, Sign: true},
+		{Key: StringKey("scopes"), Value: []byte{}},
+	}
+	for _, e := range tests {
+		if err := c.SetExtension(e); err != nil {
+			t.Fatalf("expected no error, got %s", err.Error())
+		}
+	}
+	if err := c.SetExtension(Extension{Key: StringKey("tenant"), Value: []byte("example")}); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+
+	result := &Container{}
+	if err := UnmarshalBytes(c.MarshalBytes(), result); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	extensions := result.Extensions()
+	if len(extensions) != 3 {
+		t.Fatalf("expected 3 extensions, got %d", len(extensions))
+	}
+	if extensions[0].Key != StringKey("tenant") || string(extensions[0].Value) != "example" {
+		t.Errorf("expected replaced extension to keep its position, got %s", extensions[0].Key)
+	}
+	if v, ok := result.GetExtension(NumericKey(42)); !ok || !bytes.Equal(v, []byte{1, 2, 3}) || !extensions[1].Sign {
+		t.Errorf("expected numeric extension to survive the round trip")
+	}
+	if v, ok := result.GetExtension(StringKey("scopes")); !ok || v == nil {
+		t.Errorf("expected empty extension to be present")
+	}
+	if _, ok := result.GetExtension(StringKey("42")); ok {
+		t.Errorf("expected string and numeric keys to differ")
+	}
+	if string(result.GetEmail()) != "someone@example.com" {
+		t.Errorf("expected fields to be unaffected by extensions")
+	}
+
+	if err := result.SetExtension(Extension{Key: NumericKey(42)}); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if _, ok := result.GetExtension(NumericKey(42)); ok || len(result.Extensions()) != 2 {
+		t.Errorf("expected extension with nil value to be removed")
+	}
+}
+
+func Test_Container_Extensions_Invalid(t *testing.T) {
+	c := New()
+	tests := []struct {
+		name string
+		ext  Extension
+	}{
+		{"empty name", Extension{Key: StringKey(""), Value: []byte("a")}},
+		{"long name", Extension{Key: StringKey(string(make([]byte, 256))), Value: []byte("a")}},
+		{"large value", Extension{Key: NumericKey(1), Value: make([]byte, blockMaxSize+1)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := c.SetExtension(tt.ext); err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+
+	if err := c.SetExtension(Extension{Key: StringKey("tenant"), Value: []byte("acme")}); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	b := c.MarshalBytes()
+	for _, l := range []int{len(b) - 1, len(b) - 6, len(b) - 12} {
+		if err := UnmarshalBytes(b[:l], &Container{}); err == nil {
+			t.Errorf("expected error for extension area truncated to %d bytes", l)
+		}
+	}
+}
+
+func Test_Container_Extensions_Duplicate(t *testing.T) {
+	// with more extensions than are compared pairwise, duplicates are found by a map
+	for _, n := range []int{2, extensionsPairwiseMax + 8} {
+		t.Run(fmt.Sprintf("%d extensions", n), func(t *testing.T) {
+			c := New()
+			for i := 0; i < n; i++ {
+				_ = c.SetExtension(Extension{Key: StringKey(fmt.Sprintf("key%03d", i)), Value: []byte("value")})
+			}
+			b := c.MarshalBytes()
+			// rename the last extension, so it has the same key as the first one
+			copy(b[bytes.LastIndex(b, []byte(fmt.Sprintf("key%03d", n-1))):], "key000")
+
+			if err := UnmarshalBytes(b, &Container{}); err == nil {
+				t.Errorf("expected error for duplicate extension")
+			}
+			if _, err := NewView(b); err == nil {
+				t.Errorf("expected error for duplicate extension in view")
+			}
+			lv, err := OpenReaderAt(bytes.NewReader(b), int64(len(b)))
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err.Error())
+			}
+			if _, _, err := lv.GetExtension(StringKey("key000")); err == nil {
+				t.Errorf("expected error for duplicate extension in lazy view")
+			}
+			if _, err := unmarshalExtensions(b[int(headerSize)+fieldsEnd(c.headers[:]):]); err == nil {
+				t.Errorf("expected error for duplicate extension in alternate encodings")
+			}
+		})
+	}
+}
+
+func Test_Container_Extensions_Unknown(t *testing.T) {
+	c := New()
+	_ = c.SetExtension(Extension{Key: NumericKey(7), Value: []byte("value")})
+	b := c.MarshalBytes()
+
+	// set a flag bit unknown to this version
+	at := int(headerSize) + versionSize + presenceSize + flagsSize + extensionAreaHeaderSize
+	b[at] |= 0x80
+
+	result := &Container{}
+	if err := UnmarshalBytes(b, result); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if !bytes.Equal(result.MarshalBytes(), b) {
+		t.Errorf("expected unknown extension flags to be preserved")
+	}
+}
+
+func Test_Container_Extensions_Encryption(t *testing.T) {
+	var (
+		key   = []byte("0123456789abcdef")
+		nonce = []byte("123456789012")
+	)
+	c := New().SetNonce(nonce)
+	_ = c.SetExtension(Extension{Key: StringKey("tenant"), Value: []byte("acme"), Encrypt: true})
+	_ = c.SetExtension(Extension{Key: StringKey("model"), Value: []byte("x1")})
+
+	if err := c.EncryptEverything(nonce, key); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if v, _ := c.GetExtension(StringKey("tenant")); bytes.Equal(v, []byte("acme")) || !c.IsEncrypted() {
+		t.Errorf("expected extension to be encrypted")
+	}
+	if v, _ := c.GetExtension(StringKey("model")); string(v) != "x1" {
+		t.Errorf("expected extension without Encrypt to stay plain, got '%s'", v)
+	}
+	if err := c.EncryptEverything(nonce, key); !errors.Is(err, ErrAlreadyEncrypted) {
+		t.Errorf("expected ErrAlreadyEncrypted, got %v", err)
+	}
+
+	result := &Container{}
+	if err := UnmarshalBytes(c.MarshalBytes(), result); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if err := result.DecryptEverything(nonce, key); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if v, _ := result.GetExtension(StringKey("tenant")); string(v) != "acme" || result.IsEncrypted() {
+		t.Errorf("expected 'acme', got '%s'", v)
+	}
+}
+
+func Test_Container_Extensions_Encodings(t *testing.T) {
+	var (
+		key   = []byte("0123456789abcdef")
+		nonce = []byte("123456789012")
+	)
+	c := New().SetNonce(nonce).SetEmail([]byte("someone@example.com"))
+	_ = c.SetExtension(Extension{Key: StringKey("tenant"), Value: []byte("acme"), Encrypt: true, Sign: true})
+	_ = c.SetExtension(Extension{Key: NumericKey(7), Value: []byte{}})
+	if err := c.EncryptEverything(nonce, key); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+
+	for _, enc := range alternateEncodings {
+		t.Run(enc.name, func(t *testing.T) {
+			result, err := enc.roundTrip(c)
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err.Error())
+			}
+			if !result.Equal(c) || !bytes.Equal(result.MarshalBytes(), c.MarshalBytes()) {
+				t.Fatalf("expected extensions to survive the round trip, got %v", result.Extensions())
+			}
+			if err = result.DecryptEverything(nonce, key); err != nil {
+				t.Fatalf("expected no error, got %s", err.Error())
+			}
+			if v, _ := result.GetExtension(StringKey("tenant")); string(v) != "acme" {
+				t.Errorf("expected 'acme', got '%s'", v)
+			}
+			if v, ok := result.GetExtension(NumericKey(7)); !ok || len(v) != 0 {
+				t.Errorf("expected empty extension to be present")
+			}
+		})
+	}
+
+	if _, err := unmarshalExtensions(append(c.marshalExtensions(), 0)); err == nil {
+		t.Errorf("expected error for trailing data after the extension area")
+	}
+}
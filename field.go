@@ -0,0 +1,238 @@
+package eraf
+
+import (
+	"errors"
+	"fmt"
+)
+
+// FieldID identifies a data field of a container. The values follow the order of the fields in the binary format.
+type FieldID uint8
+
+// All data fields of a container, in wire order
+const (
+	FieldNonce FieldID = iota
+	FieldTag
+	FieldSerialNumber
+	FieldIdentifier
+	FieldCertificate
+	FieldPrivateKey
+	FieldEmail
+	FieldUsername
+	FieldToken
+	FieldSignature
+	FieldRootCertificate
+	FieldPassword
+
+	fieldCount
+)
+
+var fieldNames = [fieldCount]string{
+	"nonce",
+	"tag",
+	"serialNumber",
+	"identifier",
+	"certificate",
+	"privateKey",
+	"email",
+	"username",
+	"token",
+	"signature",
+	"rootCertificate",
+	"password",
+}
+
+// encryptedFields are the fields encrypted by EncryptEverything. Nonce and tag are required for decryption.
+var encryptedFields = []FieldID{
+	FieldSerialNumber,
+	FieldIdentifier,
+	FieldCertificate,
+	FieldPrivateKey,
+	FieldEmail,
+	FieldUsername,
+	FieldToken,
+	FieldSignature,
+	FieldRootCertificate,
+	FieldPassword,
+}
+
+// secretFields are the fields whose values must not be revealed, e.g. in logs or diffs
+var secretFields = []FieldID{
+	FieldPrivateKey,
+	FieldToken,
+	FieldPassword,
+}
+
+// String returns the name of the field, e.g. serialNumber
+func (id FieldID) String() string {
+	if !id.valid() {
+		return fmt.Sprintf("FieldID(%d)", uint8(id))
+	}
+	return fieldNames[id]
+}
+
+// IsSecret reports whether the field holds a secret, i.e. the private key, the token or the password
+func (id FieldID) IsSecret() bool {
+	for _, s := range secretFields {
+		if s == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (id FieldID) valid() bool {
+	return id < fieldCount
+}
+
+// ParseFieldID returns the FieldID of the field with the given name as returned by FieldID.String
+func ParseFieldID(name string) (FieldID, error) {
+	for id, n := range fieldNames {
+		if n == name {
+			return FieldID(id), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown field '%s'", name)
+}
+
+// ErrFieldTooLarge is returned or recorded if a value exceeds the maximum field size, which is 65,535 bytes, or
+// the maximum decompressed size for values of compressed fields
+type ErrFieldTooLarge struct {
+	Field FieldID
+	Size  int
+	// Limit is the maximum size which has been exceeded
+	Limit int
+}
+
+func (e *ErrFieldTooLarge) Error() string {
+	limit := e.Limit
+	if limit == 0 {
+		limit = blockMaxSize
+	}
+	return fmt.Sprintf("field %s has %d bytes, exceeding the maximum of %d bytes", e.Field, e.Size, limit)
+}
+
+// Field is a single data field of a container as returned by Fields
+type Field struct {
+	ID    FieldID
+	Name  string
+	Value []byte
+}
+
+// Get returns the value of the given field or nil if the FieldID is unknown
+func (c *Container) Get(id FieldID) []byte {
+	if !id.valid() {
+		return nil
+	}
+	return c.get(id)
+}
+
+// Set sets the value of the given field. Setting nil makes the field absent. Unlike the field specific setters, it returns an *ErrFieldTooLarge
+// instead of truncating values exceeding 65,535 bytes.
+func (c *Container) Set(id FieldID, v []byte) error {
+	if !id.valid() {
+		return fmt.Errorf("unknown field %s", id)
+	}
+	if max := c.maxFieldSize(id); len(v) > max {
+		return &ErrFieldTooLarge{Field: id, Size: len(v), Limit: max}
+	}
+	c.fields[id] = c.own(v)
+	return nil
+}
+
+// Has reports whether the given field is present. A field is present once it has been set to a non-nil value,
+// even if that value is empty.
+func (c *Container) Has(id FieldID) bool {
+	return id.valid() && c.fields[id] != nil
+}
+
+// Clear removes the given field, so it is absent afterwards
+func (c *Container) Clear(id FieldID) {
+	if id.valid() {
+		c.fields[id] = nil
+	}
+}
+
+// Fields returns all data fields in wire order, including absent ones, whose value is nil
+func (c *Container) Fields() []Field {
+	fields := make([]Field, fieldCount)
+	for id := range fields {
+		fields[id] = Field{ID: FieldID(id), Name: fieldNames[id], Value: c.get(FieldID(id))}
+	}
+	return fields
+}
+
+// EncryptField encrypts and returns the given field using AES-GCM. The container is not altered.
+// Nonce and tag cannot be encrypted, since they are required for decryption.
+func (c *Container) EncryptField(id FieldID, nonce []byte, key []byte) ([]byte, error) {
+	if err := checkEncryptable(id); err != nil {
+		return nil, err
+	}
+	return encryptAes(key, c.fields[id], nonce)
+}
+
+// DecryptField decrypts and returns the given field. The container is not altered.
+func (c *Container) DecryptField(id FieldID, nonce []byte, key []byte) ([]byte, error) {
+	if err := checkEncryptable(id); err != nil {
+		return nil, err
+	}
+	return decryptAes(key, c.fields[id], nonce)
+}
+
+func checkEncryptable(id FieldID) error {
+	for _, e := range encryptedFields {
+		if e == id {
+			return nil
+		}
+	}
+	return fmt.Errorf("field %s cannot be encrypted", id)
+}
+
+// SetStrict enables or disables strict mode. In strict mode, the field specific setters reject values exceeding
+// 65,535 bytes and leave the field unchanged, instead of truncating them.
+func (c *Container) SetStrict(strict bool) *Container {
+	c.strict = strict
+	return c
+}
+
+// Err returns the errors recorded by the field specific setters since the container has been created, so a chain
+// of setters can be checked once at the end. Every oversized value is recorded as *ErrFieldTooLarge, whether it
+// has been truncated or, in strict mode, rejected.
+func (c *Container) Err() error {
+	return c.err
+}
+
+// set sets the value of the given field, truncating it to 65,535 bytes, or the maximum decompressed size for
+// compressed fields, unless in strict mode
+func (c *Container) set(id FieldID, v []byte) {
+	if max := c.maxFieldSize(id); len(v) > max {
+		c.err = errors.Join(c.err, &ErrFieldTooLarge{Field: id, Size: len(v), Limit: max})
+		if c.strict {
+			return
+		}
+		v = v[:max]
+	}
+	c.fields[id] = c.own(v)
+}
+
+// SetDefensiveCopies enables or disables defensive copies. By default, the setters store the given slices and
+// the getters return the stored ones, so modifying them modifies the container. With defensive copies enabled,
+// the setters store copies and the getters return copies instead, so the container cannot be altered from
+// outside, at the cost of an allocation per call. This applies to the field specific getters and setters,
+// Get, Set, Fields, GetExtension and SetExtension.
+func (c *Container) SetDefensiveCopies(enabled bool) *Container {
+	c.copying = enabled
+	return c
+}
+
+// get returns the value of the given field, copied if defensive copies are enabled
+func (c *Container) get(id FieldID) []byte {
+	return c.own(c.fields[id])
+}
+
+// own returns a copy of v if defensive copies are enabled, v otherwise. nil stays nil, so presence is kept.
+func (c *Container) own(v []byte) []byte {
+	if !c.copying || v == nil {
+		return v
+	}
+	return append([]byte{}, v...)
+}
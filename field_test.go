@@ -0,0 +1,294 @@
+package eraf
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func Test_FieldID(t *testing.T) {
+	for id := FieldID(0); id < fieldCount; id++ {
+		parsed, err := ParseFieldID(id.String())
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err.Error())
+		}
+		if parsed != id {
+			t.Errorf("expected %d, got %d", id, parsed)
+		}
+	}
+	if FieldSerialNumber.String() != "serialNumber" {
+		t.Errorf("expected name serialNumber, got %s", FieldSerialNumber)
+	}
+	if fieldCount.String() != "FieldID(12)" {
+		t.Errorf("expected name FieldID(12), got %s", fieldCount)
+	}
+	if _, err := ParseFieldID("color"); err == nil {
+		t.Errorf("expected error for unknown field name")
+	}
+}
+
+func Test_Container_GetSet(t *testing.T) {
+	c := New()
+	tests := []struct {
+		id  FieldID
+		get func() []byte
+	}{
+		{FieldNonce, c.GetNonce},
+		{FieldTag, c.GetTag},
+		{FieldSerialNumber, c.GetSerialNumber},
+		{FieldIdentifier, c.GetIdentifier},
+		{FieldCertificate, c.GetCertificate},
+		{FieldPrivateKey, c.GetPrivateKey},
+		{FieldEmail, c.GetEmail},
+		{FieldUsername, c.GetUsername},
+		{FieldToken, c.GetToken},
+		{FieldSignature, c.GetSignature},
+		{FieldRootCertificate, c.GetRootCertificate},
+		{FieldPassword, c.GetPassword},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id.String(), func(t *testing.T) {
+			value := []byte("value of " + tt.id.String())
+			if err := c.Set(tt.id, value); err != nil {
+				t.Fatalf("expected no error, got %s", err.Error())
+			}
+			if !bytes.Equal(tt.get(), value) || !bytes.Equal(c.Get(tt.id), value) {
+				t.Errorf("expected '%s', got '%s'", value, tt.get())
+			}
+		})
+	}
+
+	if err := c.Set(fieldCount, []byte("a")); err == nil {
+		t.Errorf("expected error for unknown field")
+	}
+	if c.Get(fieldCount) != nil {
+		t.Errorf("expected nil for unknown field")
+	}
+	var tooLarge *ErrFieldTooLarge
+	if err := c.Set(FieldEmail, make([]byte, blockMaxSize+1)); !errors.As(err, &tooLarge) {
+		t.Errorf("expected *ErrFieldTooLarge, got %v", err)
+	} else if tooLarge.Field != FieldEmail || tooLarge.Size != blockMaxSize+1 {
+		t.Errorf("expected field email with %d bytes, got %s with %d bytes", blockMaxSize+1, tooLarge.Field, tooLarge.Size)
+	}
+	if string(c.GetEmail()) != "value of email" {
+		t.Errorf("expected email to be unchanged after error")
+	}
+}
+
+func Test_Container_Fields(t *testing.T) {
+	c := New().SetEmail([]byte("someone@example.com"))
+	fields := c.Fields()
+	if len(fields) != int(fieldCount) {
+		t.Fatalf("expected %d fields, got %d", fieldCount, len(fields))
+	}
+	for i, f := range fields {
+		if f.ID != FieldID(i) || f.Name != f.ID.String() {
+			t.Errorf("expected field %d in wire order, got %d (%s)", i, f.ID, f.Name)
+		}
+	}
+	if string(fields[FieldEmail].Value) != "someone@example.com" || fields[FieldUsername].Value != nil {
+		t.Errorf("expected field values to match the container")
+	}
+}
+
+func Test_Container_EncryptField(t *testing.T) {
+	var (
+		key   = []byte("0123456789abcdef")
+		nonce = []byte("123456789012")
+		c     = New().SetToken([]byte("token"))
+	)
+
+	encrypted, err := c.EncryptField(FieldToken, nonce, key)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	expected, _ := c.EncryptToken(nonce, key)
+	if !bytes.Equal(encrypted, expected) {
+		t.Errorf("expected EncryptField to equal EncryptToken")
+	}
+
+	c.SetToken(encrypted)
+	decrypted, err := c.DecryptField(FieldToken, nonce, key)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if string(decrypted) != "token" {
+		t.Errorf("expected 'token', got '%s'", decrypted)
+	}
+
+	for _, id := range []FieldID{FieldNonce, FieldTag, fieldCount} {
+		if _, err = c.EncryptField(id, nonce, key); err == nil {
+			t.Errorf("expected error encrypting field %s", id)
+		}
+		if _, err = c.DecryptField(id, nonce, key); err == nil {
+			t.Errorf("expected error decrypting field %s", id)
+		}
+	}
+}
+
+func Test_Container_Strict(t *testing.T) {
+	var (
+		big    = make([]byte, blockMaxSize+10)
+		bigger = make([]byte, blockMaxSize+20)
+	)
+
+	c := New().SetCertificate(big).SetEmail([]byte("someone@example.com"))
+	if len(c.GetCertificate()) != blockMaxSize {
+		t.Errorf("expected certificate to be truncated to %d bytes, got %d", blockMaxSize, len(c.GetCertificate()))
+	}
+	var tooLarge *ErrFieldTooLarge
+	if !errors.As(c.Err(), &tooLarge) || tooLarge.Field != FieldCertificate {
+		t.Errorf("expected truncation to be recorded, got %v", c.Err())
+	}
+
+	c = New().SetStrict(true).
+		SetCertificate([]byte("cert")).
+		SetCertificate(big).
+		SetEmail([]byte("someone@example.com")).
+		SetRootCertificate(bigger)
+	if string(c.GetCertificate()) != "cert" || c.GetRootCertificate() != nil {
+		t.Errorf("expected oversized values to be rejected in strict mode")
+	}
+	if string(c.GetEmail()) != "someone@example.com" {
+		t.Errorf("expected the chain to continue after an error")
+	}
+
+	var sizes []int
+	for _, err := range c.Err().(interface{ Unwrap() []error }).Unwrap() {
+		if errors.As(err, &tooLarge) {
+			sizes = append(sizes, tooLarge.Size)
+		}
+	}
+	if len(sizes) != 2 || sizes[0] != len(big) || sizes[1] != len(bigger) {
+		t.Errorf("expected both errors to be recorded, got sizes %v", sizes)
+	}
+
+	if err := New().SetStrict(true).SetEmail([]byte("a")).Err(); err != nil {
+		t.Errorf("expected no error, got %s", err.Error())
+	}
+}
+
+func Test_Container_HasClear(t *testing.T) {
+	c := New().SetEmail([]byte{}).SetUsername([]byte("someone"))
+	if !c.Has(FieldEmail) || !c.Has(FieldUsername) || c.Has(FieldPassword) || c.Has(fieldCount) {
+		t.Errorf("expected email and username to be present only")
+	}
+	c.Clear(FieldUsername)
+	if c.Has(FieldUsername) || c.GetUsername() != nil {
+		t.Errorf("expected username to be absent after Clear")
+	}
+
+	var buf bytes.Buffer
+	c.Dump(&buf)
+	if !strings.Contains(buf.String(), "email: \n") || strings.Contains(buf.String(), "username") {
+		t.Errorf("expected dump to contain present fields only, got %s", buf.String())
+	}
+}
+
+func Test_Container_Presence(t *testing.T) {
+	c := New().SetEmail([]byte{}).SetToken([]byte("token"))
+
+	b := c.MarshalBytes()
+	if b[1] != versionSize+presenceSize {
+		t.Errorf("expected version block of %d bytes, got %d", versionSize+presenceSize, b[1])
+	}
+
+	tests := []struct {
+		name      string
+		roundTrip func() (*Container, error)
+	}{
+		{"binary", func() (*Container, error) {
+			result := &Container{}
+			return result, UnmarshalBytes(b, result)
+		}},
+		{"json", func() (*Container, error) {
+			j, err := c.MarshalJSON()
+			if err != nil {
+				return nil, err
+			}
+			result := &Container{}
+			return result, result.UnmarshalJSON(j)
+		}},
+		{"cbor", func() (*Container, error) {
+			cb, err := c.MarshalCBOR()
+			if err != nil {
+				return nil, err
+			}
+			result := &Container{}
+			return result, result.UnmarshalCBOR(cb)
+		}},
+		{"der", func() (*Container, error) {
+			der, err := c.MarshalDER()
+			if err != nil {
+				return nil, err
+			}
+			result := &Container{}
+			return result, UnmarshalDER(der, result)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.roundTrip()
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err.Error())
+			}
+			for id := FieldID(0); id < fieldCount; id++ {
+				if result.Has(id) != c.Has(id) {
+					t.Errorf("expected presence of %s to be %t", id, c.Has(id))
+				}
+			}
+			if string(result.GetToken()) != "token" {
+				t.Errorf("expected 'token', got '%s'", result.GetToken())
+			}
+		})
+	}
+
+	// files without presence bitmap carry no empty fields
+	old := New().SetToken([]byte("token")).MarshalBytes()
+	result := &Container{}
+	if err := UnmarshalBytes(old, result); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if result.Has(FieldEmail) || !result.Has(FieldToken) {
+		t.Errorf("expected zero length fields to be absent")
+	}
+}
+
+func Test_Container_DefensiveCopies(t *testing.T) {
+	var (
+		email = []byte("someone@example.com")
+		token = []byte("token")
+		value = []byte("acme")
+		c     = New().SetDefensiveCopies(true).SetEmail(email).SetEmail(email)
+	)
+	if err := c.Set(FieldToken, token); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if err := c.SetExtension(Extension{Key: StringKey("tenant"), Value: value}); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	email[0], token[0], value[0] = 'x', 'x', 'x'
+
+	c.GetEmail()[0] = 'y'
+	c.Get(FieldToken)[0] = 'y'
+	c.Fields()[FieldEmail].Value[1] = 'y'
+	got, _ := c.GetExtension(StringKey("tenant"))
+	got[0] = 'y'
+
+	tenant, _ := c.GetExtension(StringKey("tenant"))
+	if string(c.GetEmail()) != "someone@example.com" || string(c.GetToken()) != "token" || string(tenant) != "acme" {
+		t.Errorf("expected the container to be isolated, got email '%s', token '%s' and tenant '%s'",
+			c.GetEmail(), c.GetToken(), tenant)
+	}
+	if c.SetEmail([]byte{}).GetEmail() == nil || c.Has(FieldPassword) || c.GetPassword() != nil {
+		t.Errorf("expected presence to be kept")
+	}
+
+	// without defensive copies, the given slices are stored
+	c = New().SetEmail(email)
+	email[0] = 'z'
+	if c.GetEmail()[0] != 'z' {
+		t.Errorf("expected the given slice to be stored")
+	}
+}
@@ -0,0 +1,127 @@
+package eraf
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Cipher identifies the cipher the encrypted fields of a container have been encrypted with
+type Cipher uint8
+
+// All known ciphers
+const (
+	CipherNone Cipher = iota
+	// CipherAESGCM is AES-128, AES-192 or AES-256 in GCM mode, depending on key length, as used by EncryptEverything
+	CipherAESGCM
+)
+
+// String returns the name of the cipher, e.g. AES-GCM
+func (ci Cipher) String() string {
+	switch ci {
+	case CipherNone:
+		return "none"
+	case CipherAESGCM:
+		return "AES-GCM"
+	}
+	return fmt.Sprintf("Cipher(%d)", uint8(ci))
+}
+
+// KDF identifies the key derivation function the encryption key has been derived with. The SDK does not derive
+// keys for EncryptEverything itself, so the KDF has to be recorded by the caller using SetKDF.
+type KDF uint8
+
+// All known key derivation functions
+const (
+	// KDFNone means the key has been used as given
+	KDFNone KDF = iota
+	// KDFPBKDF2SHA256 is PBKDF2 with HMAC-SHA256, as used by passphrase slots of a *KeyRing
+	KDFPBKDF2SHA256
+)
+
+// String returns the name of the key derivation function, e.g. pbkdf2-sha256
+func (k KDF) String() string {
+	switch k {
+	case KDFNone:
+		return "none"
+	case KDFPBKDF2SHA256:
+		return kdfPBKDF2
+	}
+	return fmt.Sprintf("KDF(%d)", uint8(k))
+}
+
+// ErrAlreadyEncrypted is returned by EncryptEverything if a field is already encrypted
+var ErrAlreadyEncrypted = errors.New("already encrypted")
+
+// IsEncrypted reports whether any field has been encrypted according to the header flags, or any extension
+// is encrypted
+func (c *Container) IsEncrypted() bool {
+	if c.flags&flagsEncrypted != 0 {
+		return true
+	}
+	for _, e := range c.extensions {
+		if e.Encrypted {
+			return true
+		}
+	}
+	return false
+}
+
+// EncryptedFields returns the fields which have been encrypted according to the header flags, in wire order
+func (c *Container) EncryptedFields() []FieldID {
+	var ids []FieldID
+	for id := FieldID(0); id < fieldCount; id++ {
+		if c.isEncrypted(id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (c *Container) isEncrypted(id FieldID) bool {
+	return c.flags&(1<<id) != 0
+}
+
+// Cipher returns the cipher the encrypted fields have been encrypted with, CipherNone if nothing is encrypted
+func (c *Container) Cipher() Cipher {
+	return Cipher(c.flags >> cipherShift)
+}
+
+// KDF returns the key derivation function recorded by SetKDF
+func (c *Container) KDF() KDF {
+	return KDF(c.flags >> kdfShift)
+}
+
+// SetKDF records the key derivation function the encryption key has been derived with, so the receiver knows how
+// to derive it again
+func (c *Container) SetKDF(k KDF) *Container {
+	c.flags = c.flags&^(0xff<<kdfShift) | uint32(k)<<kdfShift
+	return c
+}
+
+// IsSigned reports whether the container has been marked as signed using SetSigned
+func (c *Container) IsSigned() bool {
+	return c.flags&flagSigned != 0
+}
+
+// SetSigned marks the container as signed or unsigned, e.g. after setting the signature
+func (c *Container) SetSigned(signed bool) *Container {
+	c.setFlag(flagSigned, signed)
+	return c
+}
+
+// IsCompressed reports whether any field is compressed, see SetCompression
+func (c *Container) IsCompressed() bool {
+	return c.compressed != 0
+}
+
+func (c *Container) setFlag(flag uint32, set bool) {
+	if set {
+		c.flags |= flag
+	} else {
+		c.flags &^= flag
+	}
+}
+
+func (c *Container) setCipher(ci Cipher) {
+	c.flags = c.flags&^(0xff<<cipherShift) | uint32(ci)<<cipherShift
+}
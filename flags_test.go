@@ -0,0 +1,130 @@
+package eraf
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func Test_Container_Flags(t *testing.T) {
+	var (
+		key   = []byte("0123456789abcdef")
+		nonce = []byte("123456789012")
+	)
+	c := New().SetNonce(nonce).SetEmail([]byte("someone@example.com")).SetToken([]byte("token"))
+	if c.IsEncrypted() || c.Cipher() != CipherNone || len(c.MarshalBytes()) != 53+len("someone@example.com")+len("token")+len(nonce) {
+		t.Errorf("expected new container without flags")
+	}
+
+	if err := c.EncryptEverything(nonce, key); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	c.SetKDF(KDFPBKDF2SHA256).SetSigned(true)
+
+	result := &Container{}
+	if err := UnmarshalBytes(c.MarshalBytes(), result); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	encrypted := result.EncryptedFields()
+	if !result.IsEncrypted() || len(encrypted) != 2 || encrypted[0] != FieldEmail || encrypted[1] != FieldToken {
+		t.Errorf("expected email and token to be encrypted, got %v", encrypted)
+	}
+	if result.Cipher() != CipherAESGCM || result.KDF() != KDFPBKDF2SHA256 || !result.IsSigned() || result.IsCompressed() {
+		t.Errorf("expected flags to survive the round trip, got cipher %s, KDF %s", result.Cipher(), result.KDF())
+	}
+
+	token := result.GetToken()
+	if err := result.EncryptEverything(nonce, key); !errors.Is(err, ErrAlreadyEncrypted) {
+		t.Errorf("expected ErrAlreadyEncrypted, got %v", err)
+	}
+	if !bytes.Equal(result.GetToken(), token) {
+		t.Errorf("expected token to be unchanged")
+	}
+
+	if err := result.DecryptEverything(nonce, key); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if result.IsEncrypted() || result.Cipher() != CipherNone || result.KDF() != KDFNone || !result.IsSigned() {
+		t.Errorf("expected encryption flags to be cleared")
+	}
+	if string(result.GetToken()) != "token" {
+		t.Errorf("expected 'token', got '%s'", result.GetToken())
+	}
+
+	result.SetSigned(false)
+	if result.IsSigned() || len(result.MarshalBytes()) != 53+len("someone@example.com")+len("token")+len(nonce) {
+		t.Errorf("expected flags to be omitted once cleared")
+	}
+}
+
+func Test_Container_Flags_Armor(t *testing.T) {
+	var (
+		key   = []byte("0123456789abcdef")
+		nonce = []byte("123456789012")
+	)
+	c := New().SetNonce(nonce).SetPassword([]byte("pass"))
+	if err := c.EncryptEverything(nonce, key); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+
+	b, err := c.MarshalArmored(nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if !bytes.Contains(b, []byte("Encrypted: yes")) {
+		t.Errorf("expected armor header to reflect the flags, got %s", b)
+	}
+}
+
+func Test_Container_Flags_Encodings(t *testing.T) {
+	var (
+		key   = []byte("0123456789abcdef")
+		nonce = []byte("123456789012")
+	)
+	c := New().SetNonce(nonce).SetEmail([]byte("someone@example.com")).SetToken([]byte("token"))
+	if err := c.EncryptEverything(nonce, key); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	c.SetKDF(KDFPBKDF2SHA256).SetSigned(true)
+
+	for _, enc := range alternateEncodings {
+		t.Run(enc.name, func(t *testing.T) {
+			result, err := enc.roundTrip(c)
+			if err != nil {
+				t.Fatalf("expected no error, got %s", err.Error())
+			}
+			encrypted := result.EncryptedFields()
+			if !result.IsEncrypted() || len(encrypted) != 2 || encrypted[0] != FieldEmail || encrypted[1] != FieldToken {
+				t.Errorf("expected email and token to be encrypted, got %v", encrypted)
+			}
+			if result.Cipher() != CipherAESGCM || result.KDF() != KDFPBKDF2SHA256 || !result.IsSigned() {
+				t.Errorf("expected flags to survive the round trip, got cipher %s, KDF %s", result.Cipher(), result.KDF())
+			}
+			if err = result.DecryptEverything(nonce, key); err != nil {
+				t.Fatalf("expected no error, got %s", err.Error())
+			}
+			if string(result.GetToken()) != "token" {
+				t.Errorf("expected 'token', got '%s'", result.GetToken())
+			}
+		})
+	}
+}
+
+func Test_Cipher_KDF_String(t *testing.T) {
+	tests := []struct {
+		got      string
+		expected string
+	}{
+		{CipherNone.String(), "none"},
+		{CipherAESGCM.String(), "AES-GCM"},
+		{Cipher(9).String(), "Cipher(9)"},
+		{KDFNone.String(), "none"},
+		{KDFPBKDF2SHA256.String(), "pbkdf2-sha256"},
+		{KDF(7).String(), "KDF(7)"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.expected {
+			t.Errorf("expected %s, got %s", tt.expected, tt.got)
+		}
+	}
+}
@@ -1,3 +1,3 @@
 module github.com/KaiserWerk/ERAF-Go-SDK
 
-go 1.16
+go 1.20
@@ -0,0 +1,428 @@
+package eraf
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	handshakeLabel       = "ERAF handshake v1"
+	defaultMaxFrameSize  = 1 << 20
+	maxRecordPayloadSize = 16 * 1024
+)
+
+// HandshakePolicy controls how Handshake authenticates the peer. A nil *HandshakePolicy is valid and
+// verifies the peer's certificate against the root certificate of the local container.
+type HandshakePolicy struct {
+	// Roots overrides the root certificate of the local container as trust anchor
+	Roots *x509.CertPool
+	// KeyUsages the peer certificate must be valid for. Defaults to any key usage
+	KeyUsages []x509.ExtKeyUsage
+	// CurrentTime is used to check the validity period of the peer certificate. Defaults to time.Now()
+	CurrentTime time.Time
+	// VerifyPeer, if set, is called with the peer's container once its certificate chain has been verified
+	VerifyPeer func(peer *Container, chains [][]*x509.Certificate) error
+	// MaxFrameSize is the largest handshake message accepted from the peer. Defaults to 1 MiB
+	MaxFrameSize int
+}
+
+// SecureConn is the net.Conn returned by Handshake. All data written to it is encrypted with AES-GCM using
+// a session key derived via ECDH, all data read from it is decrypted and authenticated.
+type SecureConn struct {
+	net.Conn
+	peer *Container
+
+	readMu      sync.Mutex
+	readAead    cipher.AEAD
+	readSeq     uint64
+	readBuf     []byte
+	readPending []byte
+
+	writeMu   sync.Mutex
+	writeAead cipher.AEAD
+	writeSeq  uint64
+}
+
+// Handshake performs a mutually authenticated handshake over conn. Both sides send the public part of
+// their container (private key, password and token are never sent) together with an ephemeral X25519 key,
+// verify the peer's certificate against the trust anchor and prove possession of the private key belonging
+// to their certificate by signing the handshake transcript.
+// The local container needs a PEM encoded certificate and private key and must not be encrypted.
+// The returned *SecureConn wraps conn; conn is not closed if the handshake fails.
+func Handshake(conn net.Conn, local *Container, policy *HandshakePolicy) (*SecureConn, error) {
+	if conn == nil || local == nil {
+		return nil, fmt.Errorf("connection and local container are required")
+	}
+	if policy == nil {
+		policy = &HandshakePolicy{}
+	}
+	maxFrameSize := policy.MaxFrameSize
+	if maxFrameSize <= 0 {
+		maxFrameSize = defaultMaxFrameSize
+	}
+
+	tlsCert, err := local.GetTlsCertificate()
+	if err != nil {
+		return nil, fmt.Errorf("could not load local certificate: %w", err)
+	}
+	signer, ok := tlsCert.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", tlsCert.PrivateKey)
+	}
+
+	roots := policy.Roots
+	if roots == nil {
+		rootCert, err := local.GetX509RootCertificate()
+		if err != nil {
+			return nil, fmt.Errorf("could not load local root certificate: %w", err)
+		}
+		roots = x509.NewCertPool()
+		roots.AddCert(rootCert)
+	}
+
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	// hello: ephemeral public key followed by the public container
+	hello := append(ephemeral.PublicKey().Bytes(), publicContainer(local).MarshalBytes()...)
+	peerHello, err := exchangeFrames(conn, hello, maxFrameSize)
+	if err != nil {
+		return nil, err
+	}
+	if len(peerHello) < 32 {
+		return nil, fmt.Errorf("handshake message too short")
+	}
+
+	cmp := bytes.Compare(hello, peerHello)
+	if cmp == 0 {
+		return nil, fmt.Errorf("peer reflected the handshake message")
+	}
+
+	peerKey, err := ecdh.X25519().NewPublicKey(peerHello[:32])
+	if err != nil {
+		return nil, err
+	}
+	peer := New()
+	if err = UnmarshalBytes(peerHello[32:], peer); err != nil {
+		return nil, fmt.Errorf("could not unmarshal peer container: %w", err)
+	}
+
+	peerCert, chains, err := verifyPeerCertificate(peer, roots, policy)
+	if err != nil {
+		return nil, err
+	}
+	if policy.VerifyPeer != nil {
+		if err = policy.VerifyPeer(peer, chains); err != nil {
+			return nil, err
+		}
+	}
+
+	// the side with the lexicographically smaller hello takes role 0, so both sides agree on
+	// the transcript and the direction of the session keys without any further negotiation
+	var localRole, peerRole byte = 0, 1
+	first, second := hello, peerHello
+	if cmp > 0 {
+		localRole, peerRole = 1, 0
+		first, second = peerHello, hello
+	}
+	transcript := hashTranscript(first, second)
+
+	sig, err := signTranscript(signer, transcript, localRole)
+	if err != nil {
+		return nil, err
+	}
+	peerSig, err := exchangeFrames(conn, sig, maxFrameSize)
+	if err != nil {
+		return nil, err
+	}
+	if err = verifyTranscript(peerCert.PublicKey, transcript, peerRole, peerSig); err != nil {
+		return nil, err
+	}
+
+	shared, err := ephemeral.ECDH(peerKey)
+	if err != nil {
+		return nil, err
+	}
+	writeAead, err := newSessionAead(shared, transcript, localRole)
+	if err != nil {
+		return nil, err
+	}
+	readAead, err := newSessionAead(shared, transcript, peerRole)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SecureConn{
+		Conn:      conn,
+		peer:      peer,
+		readAead:  readAead,
+		writeAead: writeAead,
+	}, nil
+}
+
+// Peer returns the authenticated public container of the peer
+func (sc *SecureConn) Peer() *Container {
+	return sc.peer
+}
+
+// Read reads and decrypts data from the connection
+func (sc *SecureConn) Read(b []byte) (int, error) {
+	sc.readMu.Lock()
+	defer sc.readMu.Unlock()
+
+	if len(sc.readPending) == 0 {
+		var lengthBytes [2]byte
+		if _, err := io.ReadFull(sc.Conn, lengthBytes[:]); err != nil {
+			return 0, err
+		}
+		length := int(binary.BigEndian.Uint16(lengthBytes[:]))
+		if cap(sc.readBuf) < length {
+			sc.readBuf = make([]byte, length)
+		}
+		record := sc.readBuf[:length]
+		if _, err := io.ReadFull(sc.Conn, record); err != nil {
+			return 0, err
+		}
+		plain, err := sc.readAead.Open(record[:0], recordNonce(sc.readSeq), record, lengthBytes[:])
+		if err != nil {
+			return 0, fmt.Errorf("could not decrypt record: %w", err)
+		}
+		sc.readSeq++
+		sc.readPending = plain
+	}
+
+	n := copy(b, sc.readPending)
+	sc.readPending = sc.readPending[n:]
+	return n, nil
+}
+
+// Write encrypts b and writes it to the connection
+func (sc *SecureConn) Write(b []byte) (int, error) {
+	sc.writeMu.Lock()
+	defer sc.writeMu.Unlock()
+
+	written := 0
+	for len(b) > 0 {
+		chunk := b
+		if len(chunk) > maxRecordPayloadSize {
+			chunk = chunk[:maxRecordPayloadSize]
+		}
+		var lengthBytes [2]byte
+		binary.BigEndian.PutUint16(lengthBytes[:], uint16(len(chunk)+sc.writeAead.Overhead()))
+		record := make([]byte, 2, 2+len(chunk)+sc.writeAead.Overhead())
+		copy(record, lengthBytes[:])
+		record = sc.writeAead.Seal(record, recordNonce(sc.writeSeq), chunk, lengthBytes[:])
+		if _, err := sc.Conn.Write(record); err != nil {
+			return written, err
+		}
+		sc.writeSeq++
+		written += len(chunk)
+		b = b[len(chunk):]
+	}
+
+	return written, nil
+}
+
+// publicContainer returns a copy of c containing only the fields that may be disclosed to a peer
+func publicContainer(c *Container) *Container {
+	return New().
+		SetVersionMajor(c.GetVersionMajor()).
+		SetVersionMinor(c.GetVersionMinor()).
+		SetVersionPatch(c.GetVersionPatch()).
+		SetSerialNumber(c.GetSerialNumber()).
+		SetIdentifier(c.GetIdentifier()).
+		SetCertificate(c.GetCertificate()).
+		SetEmail(c.GetEmail()).
+		SetUsername(c.GetUsername()).
+		SetSignature(c.GetSignature())
+}
+
+// verifyPeerCertificate verifies the peer's certificate chain. The first PEM block of the certificate field
+// is the leaf certificate, further blocks are treated as intermediates.
+func verifyPeerCertificate(peer *Container, roots *x509.CertPool, policy *HandshakePolicy) (*x509.Certificate, [][]*x509.Certificate, error) {
+	var (
+		leaf          *x509.Certificate
+		intermediates = x509.NewCertPool()
+		rest          = peer.GetCertificate()
+	)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not parse peer certificate: %w", err)
+		}
+		if leaf == nil {
+			leaf = cert
+		} else {
+			intermediates.AddCert(cert)
+		}
+	}
+	if leaf == nil {
+		return nil, nil, fmt.Errorf("peer did not send a certificate")
+	}
+
+	keyUsages := policy.KeyUsages
+	if len(keyUsages) == 0 {
+		keyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageAny}
+	}
+	chains, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   policy.CurrentTime,
+		KeyUsages:     keyUsages,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not verify peer certificate: %w", err)
+	}
+
+	return leaf, chains, nil
+}
+
+// exchangeFrames writes out and reads the peer's frame at the same time, since both sides of the
+// handshake send before they receive
+func exchangeFrames(conn net.Conn, out []byte, maxFrameSize int) ([]byte, error) {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- writeFrame(conn, out)
+	}()
+
+	in, err := readFrame(conn, maxFrameSize)
+	if err != nil {
+		// unblock the writer in case the peer is gone
+		_ = conn.SetWriteDeadline(time.Now())
+		<-errCh
+		_ = conn.SetWriteDeadline(time.Time{})
+		return nil, err
+	}
+	if err = <-errCh; err != nil {
+		return nil, err
+	}
+
+	return in, nil
+}
+
+func writeFrame(w io.Writer, b []byte) error {
+	frame := make([]byte, 4, 4+len(b))
+	binary.BigEndian.PutUint32(frame, uint32(len(b)))
+	_, err := w.Write(append(frame, b...))
+	return err
+}
+
+func readFrame(r io.Reader, maxFrameSize int) ([]byte, error) {
+	var lengthBytes [4]byte
+	if _, err := io.ReadFull(r, lengthBytes[:]); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(lengthBytes[:])
+	if int64(length) > int64(maxFrameSize) {
+		return nil, fmt.Errorf("handshake frame of %d bytes exceeds limit of %d bytes", length, maxFrameSize)
+	}
+	b := make([]byte, length)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func hashTranscript(first, second []byte) []byte {
+	h := sha256.New()
+	_, _ = h.Write([]byte(handshakeLabel))
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(first)))
+	_, _ = h.Write(length[:])
+	_, _ = h.Write(first)
+	binary.BigEndian.PutUint32(length[:], uint32(len(second)))
+	_, _ = h.Write(length[:])
+	_, _ = h.Write(second)
+	return h.Sum(nil)
+}
+
+func signTranscript(signer crypto.Signer, transcript []byte, role byte) ([]byte, error) {
+	msg := append(append([]byte(handshakeLabel), role), transcript...)
+	switch signer.Public().(type) {
+	case ed25519.PublicKey:
+		return signer.Sign(rand.Reader, msg, crypto.Hash(0))
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(msg)
+		return signer.Sign(rand.Reader, digest[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256})
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(msg)
+		return signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", signer.Public())
+	}
+}
+
+func verifyTranscript(pub crypto.PublicKey, transcript []byte, role byte, sig []byte) error {
+	msg := append(append([]byte(handshakeLabel), role), transcript...)
+	digest := sha256.Sum256(msg)
+	var ok bool
+	switch key := pub.(type) {
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(key, msg, sig)
+	case *rsa.PublicKey:
+		ok = rsa.VerifyPSS(key, crypto.SHA256, digest[:], sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil
+	case *ecdsa.PublicKey:
+		ok = ecdsa.VerifyASN1(key, digest[:], sig)
+	default:
+		return fmt.Errorf("unsupported public key type %T", pub)
+	}
+	if !ok {
+		return errors.New("invalid handshake signature")
+	}
+	return nil
+}
+
+// newSessionAead derives the AES-256-GCM key for the given sending role using HKDF-SHA256
+func newSessionAead(shared, transcript []byte, role byte) (cipher.AEAD, error) {
+	return newGcm(hkdfSha256(shared, transcript, []byte{'k', 'e', 'y', role}, 32))
+}
+
+func hkdfSha256(secret, salt, info []byte, length int) []byte {
+	extractor := hmac.New(sha256.New, salt)
+	_, _ = extractor.Write(secret)
+	prk := extractor.Sum(nil)
+
+	var (
+		out  []byte
+		prev []byte
+	)
+	for i := byte(1); len(out) < length; i++ {
+		expander := hmac.New(sha256.New, prk)
+		_, _ = expander.Write(prev)
+		_, _ = expander.Write(info)
+		_, _ = expander.Write([]byte{i})
+		prev = expander.Sum(nil)
+		out = append(out, prev...)
+	}
+	return out[:length]
+}
+
+func recordNonce(seq uint64) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[4:], seq)
+	return nonce
+}
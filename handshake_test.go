@@ -0,0 +1,201 @@
+package eraf
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+type testCA struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ERAF Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// newContainer returns a container holding a fresh certificate and key issued by the CA
+func (ca *testCA) newContainer(t *testing.T, name string) *Container {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return New().
+		SetIdentifier([]byte(name)).
+		SetCertificate(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})).
+		SetPrivateKey(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})).
+		SetRootCertificate(ca.certPEM).
+		SetToken([]byte("secret-token"))
+}
+
+type handshakeResult struct {
+	conn *SecureConn
+	err  error
+}
+
+func runHandshake(a, b net.Conn, ca, cb *Container, policy *HandshakePolicy) (handshakeResult, handshakeResult) {
+	resCh := make(chan handshakeResult, 1)
+	go func() {
+		conn, err := Handshake(b, cb, policy)
+		if err != nil {
+			_ = b.Close()
+		}
+		resCh <- handshakeResult{conn, err}
+	}()
+	conn, err := Handshake(a, ca, policy)
+	if err != nil {
+		_ = a.Close()
+	}
+	return handshakeResult{conn, err}, <-resCh
+}
+
+func Test_Handshake(t *testing.T) {
+	var (
+		ca     = newTestCA(t)
+		alice  = ca.newContainer(t, "alice")
+		bob    = ca.newContainer(t, "bob")
+		a, b   = net.Pipe()
+		msg    = bytes.Repeat([]byte("hello bob "), 5000)
+		answer = []byte("hello alice")
+	)
+	defer a.Close()
+	defer b.Close()
+
+	resA, resB := runHandshake(a, b, alice, bob, nil)
+	if resA.err != nil || resB.err != nil {
+		t.Fatalf("expected successful handshake, got errors '%v' and '%v'", resA.err, resB.err)
+	}
+
+	if string(resA.conn.Peer().GetIdentifier()) != "bob" {
+		t.Errorf("expected peer identifier 'bob', got '%s'", resA.conn.Peer().GetIdentifier())
+	}
+	if len(resB.conn.Peer().GetToken()) != 0 || len(resB.conn.Peer().GetPrivateKey()) != 0 {
+		t.Errorf("expected token and private key not to be sent to the peer")
+	}
+
+	go func() {
+		_, _ = resA.conn.Write(msg)
+	}()
+	got := make([]byte, len(msg))
+	if _, err := io.ReadFull(resB.conn, got); err != nil {
+		t.Fatalf("could not read from secure connection: %s", err.Error())
+	}
+	if !bytes.Equal(got, msg) {
+		t.Errorf("received data does not match sent data")
+	}
+
+	go func() {
+		_, _ = resB.conn.Write(answer)
+	}()
+	got = make([]byte, len(answer))
+	if _, err := io.ReadFull(resA.conn, got); err != nil {
+		t.Fatalf("could not read from secure connection: %s", err.Error())
+	}
+	if !bytes.Equal(got, answer) {
+		t.Errorf("expected answer '%s', got '%s'", answer, got)
+	}
+}
+
+func Test_Handshake_UntrustedPeer(t *testing.T) {
+	var (
+		alice = newTestCA(t).newContainer(t, "alice")
+		eve   = newTestCA(t).newContainer(t, "eve")
+		a, b  = net.Pipe()
+	)
+	defer a.Close()
+	defer b.Close()
+
+	resA, resB := runHandshake(a, b, alice, eve, nil)
+	if resA.err == nil || resB.err == nil {
+		t.Errorf("expected handshake with certificates from different roots to fail")
+	}
+}
+
+func Test_Handshake_VerifyPeer(t *testing.T) {
+	var (
+		ca     = newTestCA(t)
+		alice  = ca.newContainer(t, "alice")
+		bob    = ca.newContainer(t, "bob")
+		a, b   = net.Pipe()
+		policy = &HandshakePolicy{
+			VerifyPeer: func(peer *Container, _ [][]*x509.Certificate) error {
+				if string(peer.GetIdentifier()) == "bob" {
+					return io.ErrUnexpectedEOF
+				}
+				return nil
+			},
+		}
+	)
+	defer a.Close()
+	defer b.Close()
+
+	resA, _ := runHandshake(a, b, alice, bob, policy)
+	if resA.err == nil {
+		t.Errorf("expected handshake to be rejected by VerifyPeer")
+	}
+}
+
+func Test_Handshake_MissingKey(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+
+	if _, err := Handshake(a, New(), nil); err == nil {
+		t.Errorf("expected error for container without certificate")
+	}
+}
@@ -0,0 +1,152 @@
+package eraf
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"unicode/utf8"
+)
+
+// jsonContainer is the JSON schema of a *Container. Binary fields are base64 encoded, absent fields are omitted.
+type jsonContainer struct {
+	Version         string   `json:"version"`
+	Flags           uint32   `json:"flags,omitempty"`
+	Compressed      uint16   `json:"compressed,omitempty"`
+	Nonce           *[]byte  `json:"nonce,omitempty"`
+	Tag             *[]byte  `json:"tag,omitempty"`
+	SerialNumber    *[]byte  `json:"serialNumber,omitempty"`
+	Identifier      *[]byte  `json:"identifier,omitempty"`
+	Certificate     *pemText `json:"certificate,omitempty"`
+	PrivateKey      *pemText `json:"privateKey,omitempty"`
+	Email           *[]byte  `json:"email,omitempty"`
+	Username        *[]byte  `json:"username,omitempty"`
+	Token           *[]byte  `json:"token,omitempty"`
+	Signature       *[]byte  `json:"signature,omitempty"`
+	RootCertificate *pemText `json:"rootCertificate,omitempty"`
+	Password        *[]byte  `json:"password,omitempty"`
+	Extensions      []byte   `json:"extensions,omitempty"`
+}
+
+// pemText is a field which usually holds PEM-encoded data. It is written as plain text if it is
+// PEM-encoded and as base64 otherwise, e.g. if it has been encrypted.
+type pemText []byte
+
+var pemPrefix = []byte("-----BEGIN ")
+
+func (p pemText) MarshalJSON() ([]byte, error) {
+	// base64 never starts with a dash, so both representations can be told apart when reading
+	if bytes.HasPrefix(p, pemPrefix) && utf8.Valid(p) {
+		return json.Marshal(string(p))
+	}
+	return json.Marshal([]byte(p))
+}
+
+func (p *pemText) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	if bytes.HasPrefix([]byte(s), pemPrefix) {
+		*p = pemText(s)
+		return nil
+	}
+	v, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	*p = v
+	return nil
+}
+
+// MarshalJSON serializes the container into a JSON object with the version as a semantic version string
+// and all present fields base64 encoded. Certificates and the private key are kept as plain text if they
+// are PEM-encoded. The flags word describing encryption and signing and the compression bitmap are omitted if
+// they are zero. Compressed fields are written uncompressed unless they are encrypted. Extensions are written
+// base64 encoded as one extension area like in the binary format.
+func (c *Container) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonContainer{
+		Version:         c.GetSemVer(),
+		Flags:           c.flags,
+		Compressed:      c.compressed,
+		Nonce:           jsonBytes(c.fields[FieldNonce]),
+		Tag:             jsonBytes(c.fields[FieldTag]),
+		SerialNumber:    jsonBytes(c.fields[FieldSerialNumber]),
+		Identifier:      jsonBytes(c.fields[FieldIdentifier]),
+		Certificate:     (*pemText)(jsonBytes(c.fields[FieldCertificate])),
+		PrivateKey:      (*pemText)(jsonBytes(c.fields[FieldPrivateKey])),
+		Email:           jsonBytes(c.fields[FieldEmail]),
+		Username:        jsonBytes(c.fields[FieldUsername]),
+		Token:           jsonBytes(c.fields[FieldToken]),
+		Signature:       jsonBytes(c.fields[FieldSignature]),
+		RootCertificate: (*pemText)(jsonBytes(c.fields[FieldRootCertificate])),
+		Password:        jsonBytes(c.fields[FieldPassword]),
+		Extensions:      c.marshalExtensions(),
+	})
+}
+
+// UnmarshalJSON deserializes a JSON object as written by MarshalJSON into the container. Unknown fields
+// and fields exceeding 65,535 bytes, or the maximum decompressed size of the container if they are compressed,
+// are rejected. The settings of the container, e.g. defensive copies, are kept.
+func (c *Container) UnmarshalJSON(b []byte) error {
+	var v jsonContainer
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+
+	fields := [fieldCount][]byte{
+		fromJSON(v.Nonce),
+		fromJSON(v.Tag),
+		fromJSON(v.SerialNumber),
+		fromJSON(v.Identifier),
+		fromJSON((*[]byte)(v.Certificate)),
+		fromJSON((*[]byte)(v.PrivateKey)),
+		fromJSON(v.Email),
+		fromJSON(v.Username),
+		fromJSON(v.Token),
+		fromJSON(v.Signature),
+		fromJSON((*[]byte)(v.RootCertificate)),
+		fromJSON(v.Password),
+	}
+	result := c.decodeTarget()
+	result.compressed = v.Compressed
+	for id, f := range fields {
+		if max := result.maxFieldSize(FieldID(id)); len(f) > max {
+			return &ErrFieldTooLarge{Field: FieldID(id), Size: len(f), Limit: max}
+		}
+	}
+	extensions, err := unmarshalExtensions(v.Extensions)
+	if err != nil {
+		return err
+	}
+
+	if v.Version != "" {
+		if err := result.SetSemVer(v.Version); err != nil {
+			return err
+		}
+	}
+	result.fields = fields
+	result.extensions = extensions
+	// the extension and compression flags are derived on marshalling
+	result.flags = v.Flags &^ (flagExtensions | flagCompressed)
+
+	c.assign(result)
+	return nil
+}
+
+// jsonBytes maps absent fields to nil, so they are omitted, while present but empty fields are kept
+func jsonBytes(b []byte) *[]byte {
+	if b == nil {
+		return nil
+	}
+	return &b
+}
+
+// fromJSON is the counterpart to jsonBytes
+func fromJSON(p *[]byte) []byte {
+	if p == nil {
+		return nil
+	}
+	return append([]byte{}, *p...)
+}
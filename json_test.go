@@ -0,0 +1,134 @@
+package eraf
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func Test_Container_MarshalJSON(t *testing.T) {
+	cert := []byte("-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n")
+	c := New()
+	c.SetVersionMajor(1).SetVersionMinor(2).SetVersionPatch(3)
+	c.
+		SetNonce([]byte{0, 1, 2, 255}).
+		SetSerialNumber([]byte{7}).
+		SetIdentifier([]byte("device-42")).
+		SetCertificate(cert).
+		SetPrivateKey([]byte{0xde, 0xad}).
+		SetEmail([]byte("someone@example.com")).
+		SetRootCertificate(cert).
+		SetPassword([]byte("secret"))
+
+	b, err := json.Marshal(c)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+
+	var doc map[string]string
+	if err = json.Unmarshal(b, &doc); err != nil {
+		t.Fatalf("expected a JSON object, got %s", err.Error())
+	}
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"version", "1.2.3"},
+		{"nonce", "AAEC/w=="},
+		{"identifier", "ZGV2aWNlLTQy"},
+		{"certificate", string(cert)},
+		{"privateKey", "3q0="},
+		{"rootCertificate", string(cert)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if doc[tt.name] != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, doc[tt.name])
+			}
+		})
+	}
+	if _, ok := doc["tag"]; ok {
+		t.Errorf("expected empty field tag to be omitted")
+	}
+
+	result := &Container{}
+	if err = json.Unmarshal(b, result); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if !bytes.Equal(result.MarshalBytes(), c.MarshalBytes()) {
+		t.Errorf("expected identical binary representation after JSON round trip")
+	}
+}
+
+func Test_Container_UnmarshalJSON_Invalid(t *testing.T) {
+	tooLarge := `"` + strings.Repeat("A", (blockMaxSize+3)/3*4) + `"`
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"not an object", `[]`},
+		{"unknown field", `{"version":"1.0.0","color":"blue"}`},
+		{"invalid version", `{"version":"1.0"}`},
+		{"version out of range", `{"version":"1.256.0"}`},
+		{"invalid base64", `{"email":"not base64!"}`},
+		{"field too large", `{"token":` + tooLarge + `}`},
+		{"pem field too large", `{"certificate":` + tooLarge + `}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New().SetEmail([]byte("unchanged"))
+			if err := json.Unmarshal([]byte(tt.input), c); err == nil {
+				t.Errorf("expected an error, got nil")
+			}
+			if string(c.GetEmail()) != "unchanged" {
+				t.Errorf("expected container to be unchanged on error")
+			}
+		})
+	}
+}
+
+func Test_Container_SetSemVer(t *testing.T) {
+	c := New()
+	if err := c.SetSemVer("2.14.8"); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if c.GetSemVer() != "2.14.8" {
+		t.Errorf("expected version 2.14.8, got %s", c.GetSemVer())
+	}
+	for _, v := range []string{"", "1.2", "1.2.3.4", "1.2.x", "01.2.3", "1.2.3 "} {
+		if err := c.SetSemVer(v); err == nil {
+			t.Errorf("expected an error for version %q", v)
+		}
+	}
+}
+
+func Test_Container_UnmarshalJSON_KeepsSettings(t *testing.T) {
+	c := New()
+	if err := c.SetCompression(FieldCertificate, true); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	b, err := c.SetCertificate(bytes.Repeat([]byte("A"), 1000)).MarshalJSON()
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+
+	limited := New().SetMaxDecompressedSize(100)
+	var tooLarge *ErrFieldTooLarge
+	if err := limited.UnmarshalJSON(b); !errors.As(err, &tooLarge) || tooLarge.Limit != 100 {
+		t.Errorf("expected *ErrFieldTooLarge with limit 100, got %v", err)
+	}
+
+	copying := New().SetDefensiveCopies(true).SetChecksum(ChecksumCRC32C)
+	if err := copying.UnmarshalJSON(b); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	copying.GetCertificate()[0] = 'B'
+	if copying.GetCertificate()[0] != 'A' {
+		t.Errorf("expected defensive copies to be kept")
+	}
+	if copying.Checksum() != ChecksumCRC32C {
+		t.Errorf("expected checksum algorithm to be kept, got %v", copying.Checksum())
+	}
+}
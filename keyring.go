@@ -0,0 +1,299 @@
+package eraf
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+const (
+	kdfPBKDF2 = "pbkdf2-sha256"
+	kdfRaw    = "raw"
+
+	masterKeySize = 32
+	saltSize      = 16
+)
+
+// defaultPBKDF2Iterations is the work factor for new passphrase slots
+var defaultPBKDF2Iterations = 600000
+
+// maxPBKDF2Iterations limits the work factor of loaded slots, so a tampered key ring file cannot make Unlock
+// run for hours
+const maxPBKDF2Iterations = 10000000
+
+var (
+	// ErrKeyRingLocked is returned if the master key is required but the *KeyRing has not been unlocked
+	ErrKeyRingLocked = errors.New("key ring is locked")
+	// ErrWrongKey is returned by Unlock if no key slot can be opened with the given secret
+	ErrWrongKey = errors.New("no key slot matches the given secret")
+)
+
+// KeySlot holds a copy of the master key, encrypted with a key that is either derived from a passphrase
+// or given directly, e.g. a recovery key
+type KeySlot struct {
+	Name       string `json:"name"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations,omitempty"`
+	Salt       []byte `json:"salt,omitempty"`
+	Nonce      []byte `json:"nonce"`
+	WrappedKey []byte `json:"wrappedKey"`
+}
+
+// KeyRing protects a randomly generated master key with one or more key slots, similar to LUKS.
+// Every slot can unlock the master key on its own, so slots can be added or removed without
+// changing the master key and therefore without re-encrypting any data protected by it. It is safe for
+// concurrent use.
+type KeyRing struct {
+	mu        sync.RWMutex
+	slots     []KeySlot
+	masterKey []byte
+}
+
+// NewKeyRing creates an unlocked *KeyRing with a new random master key. Add at least one slot
+// before persisting it, otherwise the master key is lost.
+func NewKeyRing() (*KeyRing, error) {
+	key := make([]byte, masterKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	return &KeyRing{masterKey: key}, nil
+}
+
+// Slots returns the names of all key slots
+func (r *KeyRing) Slots() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, len(r.slots))
+	for i, slot := range r.slots {
+		names[i] = slot.Name
+	}
+	return names
+}
+
+// IsUnlocked reports whether the master key is available
+func (r *KeyRing) IsUnlocked() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.masterKey != nil
+}
+
+// AddPassphraseSlot adds a slot which unlocks the master key with the given passphrase.
+// The key ring has to be unlocked.
+func (r *KeyRing) AddPassphraseSlot(name string, passphrase []byte) error {
+	if len(passphrase) == 0 {
+		return fmt.Errorf("passphrase is empty")
+	}
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return err
+	}
+	slot := KeySlot{
+		Name:       name,
+		KDF:        kdfPBKDF2,
+		Iterations: defaultPBKDF2Iterations,
+		Salt:       salt,
+	}
+	return r.addSlot(slot, pbkdf2Sha256(passphrase, salt, slot.Iterations, masterKeySize))
+}
+
+// AddKeySlot adds a slot which unlocks the master key with the given key of 16, 24 or 32 bytes, e.g.
+// a randomly generated recovery key. The key ring has to be unlocked.
+func (r *KeyRing) AddKeySlot(name string, key []byte) error {
+	return r.addSlot(KeySlot{Name: name, KDF: kdfRaw}, key)
+}
+
+func (r *KeyRing) addSlot(slot KeySlot, slotKey []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.masterKey == nil {
+		return ErrKeyRingLocked
+	}
+	for _, s := range r.slots {
+		if s.Name == slot.Name {
+			return fmt.Errorf("key slot '%s' already exists", slot.Name)
+		}
+	}
+
+	nonce := make([]byte, 12)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	wrapped, err := encryptAes(slotKey, r.masterKey, nonce)
+	if err != nil {
+		return err
+	}
+	slot.Nonce = nonce
+	slot.WrappedKey = wrapped
+
+	r.slots = append(r.slots, slot)
+	return nil
+}
+
+// RemoveSlot removes the key slot with the given name. The last remaining slot cannot be removed.
+func (r *KeyRing) RemoveSlot(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, slot := range r.slots {
+		if slot.Name != name {
+			continue
+		}
+		if len(r.slots) == 1 {
+			return fmt.Errorf("cannot remove the last key slot")
+		}
+		r.slots = append(r.slots[:i], r.slots[i+1:]...)
+		return nil
+	}
+	return fmt.Errorf("key slot '%s' does not exist", name)
+}
+
+// Unlock tries to open any key slot using the given passphrase or key
+func (r *KeyRing) Unlock(secret []byte) error {
+	// the keys are derived without holding the lock, since PBKDF2 takes a while
+	r.mu.RLock()
+	slots := append([]KeySlot(nil), r.slots...)
+	r.mu.RUnlock()
+
+	for _, slot := range slots {
+		var slotKey []byte
+		switch slot.KDF {
+		case kdfPBKDF2:
+			if slot.Iterations < 1 || slot.Iterations > maxPBKDF2Iterations {
+				continue
+			}
+			slotKey = pbkdf2Sha256(secret, slot.Salt, slot.Iterations, masterKeySize)
+		case kdfRaw:
+			slotKey = secret
+		default:
+			continue
+		}
+		if key, err := decryptAes(slotKey, slot.WrappedKey, slot.Nonce); err == nil && len(key) == masterKeySize {
+			r.mu.Lock()
+			r.masterKey = key
+			r.mu.Unlock()
+			return nil
+		}
+	}
+	return ErrWrongKey
+}
+
+// Lock removes the master key from memory
+func (r *KeyRing) Lock() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.masterKey {
+		r.masterKey[i] = 0
+	}
+	r.masterKey = nil
+}
+
+// MarshalJSON serializes the key slots. The master key itself is never serialized.
+func (r *KeyRing) MarshalJSON() ([]byte, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return json.Marshal(struct {
+		Slots []KeySlot `json:"slots"`
+	}{r.slots})
+}
+
+// UnmarshalJSON deserializes the key slots. The resulting *KeyRing is locked. Passphrase slots exceeding
+// 10,000,000 PBKDF2 iterations are rejected.
+func (r *KeyRing) UnmarshalJSON(b []byte) error {
+	var v struct {
+		Slots []KeySlot `json:"slots"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	for _, slot := range v.Slots {
+		if slot.KDF == kdfPBKDF2 && (slot.Iterations < 1 || slot.Iterations > maxPBKDF2Iterations) {
+			return fmt.Errorf("key slot '%s' has %d iterations, expected between 1 and %d",
+				slot.Name, slot.Iterations, maxPBKDF2Iterations)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.slots = v.Slots
+	r.masterKey = nil
+	return nil
+}
+
+// MarshalToFile serializes the key slots into the given file using the given file permissions. The file is
+// replaced atomically, so an interrupted write never leaves a truncated key ring behind.
+func (r *KeyRing) MarshalToFile(file string, perms os.FileMode) error {
+	b, err := r.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(file, b, perms)
+}
+
+// UnmarshalKeyRingFromFile deserializes the key slots from the given file into target, which is locked afterwards
+func UnmarshalKeyRingFromFile(file string, target *KeyRing) error {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	return target.UnmarshalJSON(b)
+}
+
+// seal encrypts b with the master key. The lock is held meanwhile, so Lock cannot wipe the key while in use.
+func (r *KeyRing) seal(b, aad []byte) ([]byte, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.masterKey == nil {
+		return nil, ErrKeyRingLocked
+	}
+	return sealRandomNonce(r.masterKey, b, aad)
+}
+
+// open is the counterpart to seal
+func (r *KeyRing) open(b, aad []byte) ([]byte, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.masterKey == nil {
+		return nil, ErrKeyRingLocked
+	}
+	return openRandomNonce(r.masterKey, b, aad)
+}
+
+// DeriveKey derives a 32 byte key from a passphrase using PBKDF2 with HMAC-SHA256, e.g. for use with
+// EncryptEverything. Decryption requires the same salt and number of iterations.
+func DeriveKey(passphrase, salt []byte, iterations int) []byte {
+	return pbkdf2Sha256(passphrase, salt, iterations, masterKeySize)
+}
+
+// pbkdf2Sha256 derives a key from the password as specified in RFC 8018
+func pbkdf2Sha256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	var (
+		out   []byte
+		block [4]byte
+	)
+	for i := uint32(1); len(out) < keyLen; i++ {
+		prf.Reset()
+		_, _ = prf.Write(salt)
+		binary.BigEndian.PutUint32(block[:], i)
+		_, _ = prf.Write(block[:])
+		u := prf.Sum(nil)
+		t := append([]byte{}, u...)
+		for n := 1; n < iterations; n++ {
+			prf.Reset()
+			_, _ = prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		out = append(out, t...)
+	}
+	return out[:keyLen]
+}
@@ -0,0 +1,150 @@
+package eraf
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func init() {
+	// keep the tests fast
+	defaultPBKDF2Iterations = 1000
+}
+
+func Test_pbkdf2Sha256(t *testing.T) {
+	// test vector from RFC 7914, section 11
+	expected, _ := hex.DecodeString("55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc" +
+		"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783")
+
+	if got := pbkdf2Sha256([]byte("passwd"), []byte("salt"), 1, 64); !bytes.Equal(got, expected) {
+		t.Errorf("expected %x, got %x", expected, got)
+	}
+}
+
+func Test_KeyRing(t *testing.T) {
+	var (
+		passphrase  = []byte("correct horse battery staple")
+		recoveryKey = bytes.Repeat([]byte{7}, 32)
+		file        = filepath.Join(t.TempDir(), "keyring.json")
+	)
+
+	ring, err := NewKeyRing()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	masterKey := append([]byte{}, ring.masterKey...)
+
+	if err = ring.AddPassphraseSlot("operator", passphrase); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err = ring.AddKeySlot("recovery", recoveryKey); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err = ring.AddKeySlot("recovery", recoveryKey); err == nil {
+		t.Errorf("expected error for duplicate slot name")
+	}
+	if err = ring.MarshalToFile(file, 0600); err != nil {
+		t.Fatal(err.Error())
+	}
+	// written atomically, replacing the previous file
+	if err = ring.MarshalToFile(file, 0600); err != nil {
+		t.Fatal(err.Error())
+	}
+	entries, err := os.ReadDir(filepath.Dir(file))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(entries) != 1 || entries[0].Name() != "keyring.json" {
+		t.Errorf("expected only the key ring file, got %d entries", len(entries))
+	}
+	if info, err := os.Stat(file); err != nil {
+		t.Fatal(err.Error())
+	} else if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("expected file mode 0600, got %v", info.Mode().Perm())
+	}
+
+	loaded := &KeyRing{}
+	if err = UnmarshalKeyRingFromFile(file, loaded); err != nil {
+		t.Fatal(err.Error())
+	}
+	if loaded.IsUnlocked() {
+		t.Fatalf("expected loaded key ring to be locked")
+	}
+	if err = loaded.AddKeySlot("other", recoveryKey); !errors.Is(err, ErrKeyRingLocked) {
+		t.Errorf("expected ErrKeyRingLocked, got %v", err)
+	}
+	if err = loaded.Unlock([]byte("wrong")); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("expected ErrWrongKey, got %v", err)
+	}
+
+	for _, secret := range [][]byte{passphrase, recoveryKey} {
+		loaded.Lock()
+		if err = loaded.Unlock(secret); err != nil {
+			t.Fatalf("could not unlock: %s", err.Error())
+		}
+		if !bytes.Equal(loaded.masterKey, masterKey) {
+			t.Errorf("expected the original master key after unlocking")
+		}
+	}
+
+	if err = loaded.RemoveSlot("operator"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err = loaded.RemoveSlot("recovery"); err == nil {
+		t.Errorf("expected error when removing the last slot")
+	}
+	loaded.Lock()
+	if err = loaded.Unlock(passphrase); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("expected removed slot not to unlock the key ring, got %v", err)
+	}
+}
+
+func Test_KeyRing_Iterations(t *testing.T) {
+	for _, iterations := range []int{0, -1, maxPBKDF2Iterations + 1} {
+		b := []byte(fmt.Sprintf(`{"slots":[{"name":"operator","kdf":"pbkdf2-sha256","iterations":%d,"salt":"AAAA","nonce":"AAAA","wrappedKey":"AAAA"}]}`, iterations))
+		if err := (&KeyRing{}).UnmarshalJSON(b); err == nil {
+			t.Errorf("expected error for %d iterations", iterations)
+		}
+	}
+}
+
+func Test_KeyRing_Concurrent(t *testing.T) {
+	recoveryKey := bytes.Repeat([]byte{7}, 32)
+	ring, err := NewKeyRing()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err = ring.AddKeySlot("recovery", recoveryKey); err != nil {
+		t.Fatal(err.Error())
+	}
+	s := NewEncryptedStore(NewMemoryStore(), ring)
+
+	// run with -race; locking must not interfere with a store using the key ring
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				c := New().SetIdentifier([]byte{byte(i)}).SetSerialNumber([]byte{byte(j)})
+				if err := s.Put(c); err == nil {
+					_, _ = s.Get(c.GetIdentifier(), c.GetSerialNumber())
+				} else if !errors.Is(err, ErrKeyRingLocked) {
+					t.Errorf("expected no error or ErrKeyRingLocked, got %s", err.Error())
+				}
+				if i == 0 {
+					ring.Lock()
+					_ = ring.Unlock(recoveryKey)
+				}
+				_ = ring.Slots()
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,156 @@
+package eraf
+
+import "encoding/binary"
+
+// The version block consists of major, minor and patch, optionally followed by meta data describing the fields.
+// Older versions of this SDK only read the first three bytes of the version block and address all fields by
+// their absolute position, so they can still read containers carrying meta data. Meta data is only written if
+// it is required, otherwise the version block keeps its original length of three bytes.
+//
+//	offset 0, 2 bytes: presence bitmap, bit n is set if the field with FieldID n is present
+//	offset 2, 4 bytes: flags, see below
+//	offset 6, 1 byte:  the Checksum algorithm of the trailer
+//	offset 7, 2 bytes: compression bitmap, bit n is set if the field with FieldID n is compressed
+//
+// The flags word describes the state of the fields:
+//
+//	bits 0-11:  bit n is set if the field with FieldID n is encrypted
+//	bit 12:     the container is signed
+//	bit 13:     at least one field is compressed, derived from the compression bitmap
+//	bit 14:     the extension area follows the fields
+//	bits 16-23: the Cipher used for encryption
+//	bits 24-31: the KDF used to derive the encryption key
+//
+// Each entry is only written if it or any entry following it is required, e.g. presence and flags are always
+// written along with the checksum algorithm.
+const (
+	versionSize  = 3
+	presenceSize = 2
+	flagsSize    = 4
+	checksumSize = 1
+	compressSize = 2
+
+	flagSigned     uint32 = 1 << 12
+	flagCompressed uint32 = 1 << 13
+	flagsEncrypted uint32 = 1<<uint32(fieldCount) - 1
+	cipherShift           = 16
+	kdfShift              = 24
+)
+
+// metaLen returns the length of the meta data to be stored after the version, 0 if none is required
+func (c *Container) metaLen() int {
+	switch {
+	case c.compressed != 0:
+		return presenceSize + flagsSize + checksumSize + compressSize
+	case c.checksum != ChecksumNone:
+		return presenceSize + flagsSize + checksumSize
+	case c.flagsWord() != 0:
+		return presenceSize + flagsSize
+	case c.needsPresence():
+		return presenceSize
+	}
+	return 0
+}
+
+// flagsWord returns the flags word including the derived flags
+func (c *Container) flagsWord() uint32 {
+	flags := c.flags
+	if len(c.extensions) > 0 {
+		flags |= flagExtensions
+	}
+	if c.compressed != 0 {
+		flags |= flagCompressed
+	}
+	return flags
+}
+
+// appendMeta appends the meta data to be stored after the version to b
+func (c *Container) appendMeta(b []byte) []byte {
+	size := c.metaLen()
+	if size == 0 {
+		return b
+	}
+	b = binary.BigEndian.AppendUint16(b, c.presence())
+	if size > presenceSize {
+		b = binary.BigEndian.AppendUint32(b, c.flagsWord())
+	}
+	if size > presenceSize+flagsSize {
+		b = append(b, byte(c.checksum))
+	}
+	if size > presenceSize+flagsSize+checksumSize {
+		b = binary.BigEndian.AppendUint16(b, c.compressed)
+	}
+	return b
+}
+
+// versionLen returns the length of the version block including meta data
+func (c *Container) versionLen() int {
+	return versionSize + c.metaLen()
+}
+
+// needsPresence reports whether any field is present, but empty. Otherwise presence can be derived from the
+// field lengths.
+func (c *Container) needsPresence() bool {
+	for _, f := range c.fields {
+		if f != nil && len(f) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Container) presence() uint16 {
+	var p uint16
+	for id, f := range c.fields {
+		if f != nil {
+			p |= 1 << id
+		}
+	}
+	return p
+}
+
+// applyMeta evaluates the meta data read from the version block. Without a presence bitmap, e.g. in files
+// written by older versions, empty fields are absent. Without flags, nothing is known to be encrypted.
+func (c *Container) applyMeta(meta []byte) {
+	var presence uint16
+	if len(meta) >= presenceSize {
+		presence = binary.BigEndian.Uint16(meta)
+	}
+	// the extension and compression flags are derived on marshalling
+	c.flags = metaFlags(meta) &^ (flagExtensions | flagCompressed)
+	c.checksum = metaChecksum(meta)
+	c.compressed = metaCompressed(meta)
+	for id, f := range c.fields {
+		if len(f) == 0 {
+			if presence&(1<<id) != 0 {
+				c.fields[id] = []byte{}
+			} else {
+				c.fields[id] = nil
+			}
+		}
+	}
+}
+
+// metaFlags returns the flags word stored in the given meta data, 0 if there is none
+func metaFlags(meta []byte) uint32 {
+	if len(meta) < presenceSize+flagsSize {
+		return 0
+	}
+	return binary.BigEndian.Uint32(meta[presenceSize:])
+}
+
+// metaChecksum returns the checksum algorithm stored in the given meta data, ChecksumNone if there is none
+func metaChecksum(meta []byte) Checksum {
+	if len(meta) < presenceSize+flagsSize+checksumSize {
+		return ChecksumNone
+	}
+	return Checksum(meta[presenceSize+flagsSize])
+}
+
+// metaCompressed returns the compression bitmap stored in the given meta data, 0 if there is none
+func metaCompressed(meta []byte) uint16 {
+	if len(meta) < presenceSize+flagsSize+checksumSize+compressSize {
+		return 0
+	}
+	return binary.BigEndian.Uint16(meta[presenceSize+flagsSize+checksumSize:])
+}
@@ -0,0 +1,161 @@
+package eraf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// LazyView provides random access to the fields of a container stored in an io.ReaderAt, e.g. an *os.File or
+// a single entry of a bundle via io.NewSectionReader. Only the header and the version block are read when it is
+// opened, each field is read on demand.
+//
+// Since the checksum trailer covers the whole container, it is only verified by ToContainer. Compressed fields
+// are decompressed, encrypted fields are returned encrypted.
+type LazyView struct {
+	r               io.ReaderAt
+	headers         [headerSize]byte
+	version         []byte
+	payloadLen      int64
+	maxDecompressed int
+}
+
+// OpenReaderAt reads the header of the container of the given size stored in r
+func OpenReaderAt(r io.ReaderAt, size int64) (*LazyView, error) {
+	if size < int64(headerSize) {
+		return nil, fmt.Errorf("container is not large enough")
+	}
+	lv := &LazyView{r: r, payloadLen: size - int64(headerSize)}
+	if err := readFullAt(r, lv.headers[:], 0); err != nil {
+		return nil, err
+	}
+	if err := checkBounds(lv.headers[:], int(lv.payloadLen)); err != nil {
+		return nil, err
+	}
+
+	lv.version = make([]byte, lv.headers[1])
+	if err := readFullAt(r, lv.version, int64(headerSize)+int64(lv.headers[0])); err != nil {
+		return nil, err
+	}
+	if ch := metaChecksum(lv.meta()); ch != ChecksumNone {
+		if ch.digestSize() == 0 {
+			return nil, fmt.Errorf("unsupported checksum %s", ch)
+		}
+		lv.payloadLen -= int64(ch.trailerLen())
+		if lv.payloadLen < 0 {
+			return nil, fmt.Errorf("checksum trailer is truncated")
+		}
+		if err := checkBounds(lv.headers[:], int(lv.payloadLen)); err != nil {
+			return nil, err
+		}
+	}
+	return lv, nil
+}
+
+func (lv *LazyView) meta() []byte {
+	return lv.version[versionSize:]
+}
+
+// SetMaxDecompressedSize sets the maximum size of a single field after decompression, see
+// Container.SetMaxDecompressedSize
+func (lv *LazyView) SetMaxDecompressedSize(n int) *LazyView {
+	lv.maxDecompressed = n
+	return lv
+}
+
+// SemVer returns the version in semantic versioning format, e.g. 1.2.3
+func (lv *LazyView) SemVer() string {
+	return fmt.Sprintf("%d.%d.%d", lv.version[0], lv.version[1], lv.version[2])
+}
+
+// Has reports whether the given field is present. Nothing is read.
+func (lv *LazyView) Has(id FieldID) bool {
+	if !id.valid() {
+		return false
+	}
+	if binary.BigEndian.Uint16(lv.headers[4+4*int(id):]) > 0 {
+		return true
+	}
+	meta := lv.meta()
+	return len(meta) >= presenceSize && binary.BigEndian.Uint16(meta)&(1<<id) != 0
+}
+
+// IsEncrypted reports whether the given field is encrypted according to the header flags. Nothing is read.
+func (lv *LazyView) IsEncrypted(id FieldID) bool {
+	return id.valid() && metaFlags(lv.meta())&(1<<id) != 0
+}
+
+// Get reads the given field. Absent fields are returned as nil.
+func (lv *LazyView) Get(id FieldID) ([]byte, error) {
+	if !id.valid() {
+		return nil, fmt.Errorf("unknown field %s", id)
+	}
+	if !lv.Has(id) {
+		return nil, nil
+	}
+	at := 2 + 4*int(id)
+	position := binary.BigEndian.Uint16(lv.headers[at : at+2])
+	b := make([]byte, binary.BigEndian.Uint16(lv.headers[at+2:at+4]))
+	if err := readFullAt(lv.r, b, int64(headerSize)+int64(position)); err != nil {
+		return nil, err
+	}
+
+	if metaCompressed(lv.meta())&(1<<id) != 0 && !lv.IsEncrypted(id) && len(b) > 0 {
+		out, err := inflate(b, decompressionLimit(lv.maxDecompressed))
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", id, err)
+		}
+		return out, nil
+	}
+	return b, nil
+}
+
+// GetExtension reads the extension area and returns the value of the extension with the given key and whether
+// it exists
+func (lv *LazyView) GetExtension(key ExtensionKey) ([]byte, bool, error) {
+	if metaFlags(lv.meta())&flagExtensions == 0 {
+		return nil, false, nil
+	}
+	// the extension area is the rest of the payload
+	start := int64(fieldsEnd(lv.headers[:]))
+	area := make([]byte, lv.payloadLen-start)
+	if err := readFullAt(lv.r, area, int64(headerSize)+start); err != nil {
+		return nil, false, err
+	}
+
+	entries, err := extensionEntries(area)
+	if err != nil {
+		return nil, false, err
+	}
+	var (
+		value []byte
+		found bool
+	)
+	for len(entries) > 0 {
+		var raw rawExtension
+		if raw, entries, err = nextExtension(entries); err != nil {
+			return nil, false, err
+		}
+		if raw.matches(key) {
+			// the remaining entries are read nonetheless, so a duplicate key is rejected as by Unmarshal
+			if found {
+				return nil, false, fmt.Errorf("duplicate extension %s", key)
+			}
+			value, found = raw.value, true
+		}
+	}
+	return value, found, nil
+}
+
+// ToContainer reads the whole container, verifying the checksum trailer if there is one
+func (lv *LazyView) ToContainer() (*Container, error) {
+	b := make([]byte, int64(headerSize)+lv.payloadLen+int64(metaChecksum(lv.meta()).trailerLen()))
+	if err := readFullAt(lv.r, b, 0); err != nil {
+		return nil, err
+	}
+	c := New().SetMaxDecompressedSize(lv.maxDecompressed)
+	if err := UnmarshalNoCopy(b, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
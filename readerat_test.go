@@ -0,0 +1,103 @@
+package eraf
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_OpenReaderAt(t *testing.T) {
+	c := viewContainer()
+	_ = c.SetCompression(FieldRootCertificate, true)
+	c.SetRootCertificate([]byte(strings.Repeat("root certificate ", 5000)))
+	b := c.MarshalBytes()
+
+	r := &countingReaderAt{r: bytes.NewReader(b)}
+	lv, err := OpenReaderAt(r, int64(len(b)))
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	sn, err := lv.Get(FieldSerialNumber)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if !bytes.Equal(sn, []byte{4, 2}) || lv.SemVer() != "1.2.3" {
+		t.Errorf("expected serial number 0x0402, got %x", sn)
+	}
+	if r.n != int(headerSize)+int(b[1])+len(sn) {
+		t.Errorf("expected only header, version block and serial number to be read, read %d bytes", r.n)
+	}
+
+	for _, f := range c.Fields() {
+		v, err := lv.Get(f.ID)
+		if err != nil {
+			t.Fatalf("expected no error, got %s", err.Error())
+		}
+		if !bytes.Equal(v, f.Value) || (v == nil) != (f.Value == nil) || lv.Has(f.ID) != c.Has(f.ID) {
+			t.Errorf("expected field %s to equal the container", f.Name)
+		}
+	}
+	if x, ok, err := lv.GetExtension(NumericKey(7)); err != nil || !ok || string(x) != "seven" {
+		t.Errorf("expected extension #7, got '%s' and %v", x, err)
+	}
+	if _, err = lv.Get(fieldCount); err == nil {
+		t.Errorf("expected error for unknown field")
+	}
+
+	copied, err := lv.ToContainer()
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if !bytes.Equal(copied.MarshalBytes(), b) {
+		t.Errorf("expected ToContainer to read the whole container")
+	}
+}
+
+func Test_OpenReaderAt_File(t *testing.T) {
+	var (
+		file = filepath.Join(t.TempDir(), "container.eraf")
+		c    = New().SetIdentifier([]byte("device-42")).SetCertificate(make([]byte, 40000))
+	)
+	if err := c.MarshalToFile(file, 0600); err != nil {
+		t.Fatal(err.Error())
+	}
+	fh, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer fh.Close()
+	info, err := fh.Stat()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	lv, err := OpenReaderAt(fh, info.Size())
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if id, err := lv.Get(FieldIdentifier); err != nil || string(id) != "device-42" {
+		t.Errorf("expected identifier 'device-42', got '%s' and %v", id, err)
+	}
+	if _, ok, err := lv.GetExtension(StringKey("tenant")); ok || err != nil {
+		t.Errorf("expected no extensions")
+	}
+
+	// the size must not exceed the data
+	if lv, err = OpenReaderAt(fh, info.Size()-1); err == nil {
+		if _, err = lv.Get(FieldCertificate); err == nil {
+			t.Errorf("expected error for truncated container")
+		}
+	}
+}
+
+func Test_OpenReaderAt_Invalid(t *testing.T) {
+	b := viewContainer().MarshalBytes()
+	for _, size := range []int{0, 10, int(headerSize) + 3} {
+		if _, err := OpenReaderAt(io.NewSectionReader(bytes.NewReader(b), 0, int64(size)), int64(size)); err == nil {
+			t.Errorf("expected error for %d bytes", size)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package eraf
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrReplayed is returned when a container with an already seen combination of serial number and nonce
+	// is validated again
+	ErrReplayed = errors.New("container was already received (reused nonce)")
+	// ErrMissingNonce is returned when replay protection is requested for a container without a nonce
+	ErrMissingNonce = errors.New("container has no nonce")
+)
+
+// ReplayGuard remembers the (serial number, nonce) pairs of received containers for a limited time, so
+// a captured container cannot be accepted twice. It is safe for concurrent use.
+type ReplayGuard struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	seen      map[string]time.Time
+	file      string
+	nextSweep time.Time
+	now       func() time.Time
+}
+
+// NewReplayGuard creates an in-memory *ReplayGuard. Seen pairs are forgotten after ttl, so the sender's
+// nonces must not repeat within that time frame. It panics if ttl is not positive, since such a guard would
+// forget every pair immediately and accept every replay.
+func NewReplayGuard(ttl time.Duration) *ReplayGuard {
+	if ttl <= 0 {
+		panic("eraf: non-positive ttl for NewReplayGuard")
+	}
+	return &ReplayGuard{
+		ttl:  ttl,
+		seen: make(map[string]time.Time),
+		now:  time.Now,
+	}
+}
+
+// NewFileReplayGuard creates a *ReplayGuard which persists seen pairs into the given file, so replays are
+// also detected after a restart. Existing, not yet expired entries are loaded from the file. A ttl which is
+// not positive is rejected.
+func NewFileReplayGuard(file string, ttl time.Duration) (*ReplayGuard, error) {
+	if ttl <= 0 {
+		return nil, fmt.Errorf("ttl must be positive, got %s", ttl)
+	}
+	g := NewReplayGuard(ttl)
+	g.file = file
+
+	fh, err := os.Open(file)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		defer func() {
+			_ = fh.Close()
+		}()
+		now := g.now()
+		scanner := bufio.NewScanner(fh)
+		for scanner.Scan() {
+			parts := strings.SplitN(scanner.Text(), " ", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			expiry, err := strconv.ParseInt(parts[0], 10, 64)
+			if err != nil {
+				continue
+			}
+			if t := time.Unix(0, expiry); t.After(now) {
+				g.seen[parts[1]] = t
+			}
+		}
+		if err = scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	// drop expired entries from the file
+	if err = g.rewrite(); err != nil {
+		return nil, err
+	}
+
+	return g, nil
+}
+
+// Check records the pair of serial number and nonce and returns ErrReplayed if it has been seen before
+// and is not yet expired. It must only be called for authenticated containers, since a forged container
+// would burn the nonce of the legitimate one.
+func (g *ReplayGuard) Check(serialNumber, nonce []byte) error {
+	if len(nonce) == 0 {
+		return ErrMissingNonce
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	swept := now.After(g.nextSweep)
+	if swept {
+		g.evict(now)
+		g.nextSweep = now.Add(g.ttl / 2)
+	}
+
+	key := replayKey(serialNumber, nonce)
+	if expiry, ok := g.seen[key]; ok && expiry.After(now) {
+		return ErrReplayed
+	}
+	expiry := now.Add(g.ttl)
+	g.seen[key] = expiry
+
+	// the file is compacted along with the sweeps, so it does not grow without bounds between calls of Evict
+	if swept {
+		return g.rewrite()
+	}
+	if g.file != "" {
+		fh, err := os.OpenFile(g.file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(fh, "%d %s\n", expiry.UnixNano(), key)
+		if cerr := fh.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Len returns the number of remembered pairs, including expired ones which have not been evicted yet
+func (g *ReplayGuard) Len() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.seen)
+}
+
+// Evict removes all expired pairs. If the guard is file-backed, the file is compacted as well.
+// Expired pairs are also evicted, and the file is compacted, automatically from time to time by Check.
+func (g *ReplayGuard) Evict() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.evict(g.now())
+	return g.rewrite()
+}
+
+func (g *ReplayGuard) evict(now time.Time) {
+	for key, expiry := range g.seen {
+		if !expiry.After(now) {
+			delete(g.seen, key)
+		}
+	}
+}
+
+// rewrite replaces the backing file with the current set of pairs
+func (g *ReplayGuard) rewrite() error {
+	if g.file == "" {
+		return nil
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(g.file), ".replay-*")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(tmp)
+	for key, expiry := range g.seen {
+		_, _ = fmt.Fprintf(w, "%d %s\n", expiry.UnixNano(), key)
+	}
+	err = w.Flush()
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), 0600); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), g.file)
+}
+
+func replayKey(serialNumber, nonce []byte) string {
+	return hex.EncodeToString(serialNumber) + ":" + hex.EncodeToString(nonce)
+}
@@ -0,0 +1,171 @@
+package eraf
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_ReplayGuard_Check(t *testing.T) {
+	var (
+		g     = NewReplayGuard(time.Minute)
+		nonce = []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+	)
+
+	if err := g.Check([]byte{1}, nonce); err != nil {
+		t.Fatalf("expected first check to pass, got error '%s'", err.Error())
+	}
+	if err := g.Check([]byte{1}, nonce); !errors.Is(err, ErrReplayed) {
+		t.Errorf("expected ErrReplayed, got %v", err)
+	}
+	if err := g.Check([]byte{2}, nonce); err != nil {
+		t.Errorf("expected different serial number to pass, got error '%s'", err.Error())
+	}
+	if err := g.Check([]byte{1}, nil); !errors.Is(err, ErrMissingNonce) {
+		t.Errorf("expected ErrMissingNonce, got %v", err)
+	}
+}
+
+func Test_ReplayGuard_Expiry(t *testing.T) {
+	var (
+		g     = NewReplayGuard(time.Minute)
+		now   = time.Now()
+		nonce = []byte{1, 2, 3}
+	)
+	g.now = func() time.Time { return now }
+
+	if err := g.Check(nil, nonce); err != nil {
+		t.Fatalf("expected first check to pass, got error '%s'", err.Error())
+	}
+
+	now = now.Add(2 * time.Minute)
+	if err := g.Check(nil, nonce); err != nil {
+		t.Errorf("expected expired pair to be accepted again, got error '%s'", err.Error())
+	}
+
+	now = now.Add(2 * time.Minute)
+	if err := g.Evict(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if g.Len() != 0 {
+		t.Errorf("expected all pairs to be evicted, got %d", g.Len())
+	}
+}
+
+func Test_FileReplayGuard(t *testing.T) {
+	var (
+		file  = filepath.Join(t.TempDir(), "replay.db")
+		nonce = []byte{9, 8, 7}
+	)
+
+	g, err := NewFileReplayGuard(file, time.Hour)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err = g.Check([]byte{1}, nonce); err != nil {
+		t.Fatalf("expected first check to pass, got error '%s'", err.Error())
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if perm := info.Mode().Perm(); perm&0077 != 0 {
+		t.Errorf("expected file to be private, got mode %o", perm)
+	}
+
+	g2, err := NewFileReplayGuard(file, time.Hour)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err = g2.Check([]byte{1}, nonce); !errors.Is(err, ErrReplayed) {
+		t.Errorf("expected ErrReplayed after reload, got %v", err)
+	}
+}
+
+func Test_FileReplayGuard_Compaction(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "replay.db")
+	g, err := NewFileReplayGuard(file, time.Minute)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	now := time.Now()
+	g.now = func() time.Time { return now }
+
+	for i := byte(0); i < 3; i++ {
+		if err = g.Check([]byte{i}, []byte{1, 2, 3}); err != nil {
+			t.Fatalf("expected check to pass, got error '%s'", err.Error())
+		}
+	}
+	now = now.Add(2 * time.Minute)
+	if err = g.Check([]byte{1}, []byte{4, 5, 6}); err != nil {
+		t.Fatalf("expected check to pass, got error '%s'", err.Error())
+	}
+
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if lines := bytes.Count(b, []byte("\n")); lines != 1 {
+		t.Errorf("expected expired pairs to be dropped from the file, got %d lines", lines)
+	}
+}
+
+func Test_ReplayGuard_TTL(t *testing.T) {
+	for _, ttl := range []time.Duration{0, -time.Second} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic for ttl %s", ttl)
+				}
+			}()
+			NewReplayGuard(ttl)
+		}()
+		if _, err := NewFileReplayGuard(filepath.Join(t.TempDir(), "seen"), ttl); err == nil {
+			t.Errorf("expected error for ttl %s", ttl)
+		}
+	}
+}
+
+func Test_Container_Validate(t *testing.T) {
+	var (
+		g = NewReplayGuard(time.Minute)
+		c = New().SetSerialNumber([]byte{1, 2}).SetNonce([]byte{3, 4, 5})
+	)
+
+	if err := c.Validate(); err != nil {
+		t.Errorf("expected container to be valid, got error '%s'", err.Error())
+	}
+	if err := c.Validate(WithReplayGuard(g)); err != nil {
+		t.Errorf("expected container to be valid, got error '%s'", err.Error())
+	}
+	if err := c.Validate(WithReplayGuard(g)); !errors.Is(err, ErrReplayed) {
+		t.Errorf("expected ErrReplayed, got %v", err)
+	}
+
+	// the pair of an encrypted container is not authenticated yet, so it must not be recorded
+	var (
+		key       = []byte("0123456789abcdef")
+		encrypted = New().SetSerialNumber([]byte{6}).SetNonce([]byte("123456789012")).SetEmail([]byte("a"))
+	)
+	if err := encrypted.EncryptEverything(encrypted.GetNonce(), key); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if err := encrypted.Validate(WithReplayGuard(g)); !errors.Is(err, ErrAlreadyEncrypted) {
+		t.Errorf("expected ErrAlreadyEncrypted, got %v", err)
+	}
+	if err := encrypted.DecryptEverything(encrypted.GetNonce(), key); err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	if err := encrypted.Validate(WithReplayGuard(g)); err != nil {
+		t.Errorf("expected decrypted container to be valid, got error '%s'", err.Error())
+	}
+
+	big := make([]byte, blockMaxSize)
+	if err := New().SetCertificate(big).SetEmail([]byte("a")).Validate(); err == nil {
+		t.Errorf("expected error for oversized payload")
+	}
+}
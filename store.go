@@ -0,0 +1,148 @@
+package eraf
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// ErrNotFound is returned by a Store if no container exists for the given identifier and serial number
+var ErrNotFound = errors.New("container not found")
+
+// Store persists containers, keyed by the combination of identifier and serial number
+type Store interface {
+	// Get returns the container with the given identifier and serial number
+	Get(identifier, serialNumber []byte) (*Container, error)
+	// Put adds the container to the store, replacing any container with the same identifier and serial number
+	Put(c *Container) error
+	// Delete removes the container with the given identifier and serial number
+	Delete(identifier, serialNumber []byte) error
+	// List returns all containers in the store
+	List() ([]*Container, error)
+	// Find returns all containers matching the query
+	Find(q Query) ([]*Container, error)
+}
+
+// Query selects containers from a Store. Only fields which are not nil are compared, so the zero
+// Query matches every container.
+type Query struct {
+	Identifier   []byte
+	SerialNumber []byte
+	Email        []byte
+	Username     []byte
+}
+
+// Matches reports whether the container matches the query
+func (q Query) Matches(c *Container) bool {
+	return q.matches(c.fields[FieldIdentifier], c.fields[FieldSerialNumber], c.fields[FieldEmail], c.fields[FieldUsername])
+}
+
+func (q Query) matches(identifier, serialNumber, email, username []byte) bool {
+	return (q.Identifier == nil || bytes.Equal(q.Identifier, identifier)) &&
+		(q.SerialNumber == nil || bytes.Equal(q.SerialNumber, serialNumber)) &&
+		(q.Email == nil || bytes.Equal(q.Email, email)) &&
+		(q.Username == nil || bytes.Equal(q.Username, username))
+}
+
+// storeKey returns a fixed length key for the combination of identifier and serial number, which is
+// also safe to use as file name
+func storeKey(identifier, serialNumber []byte) string {
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(identifier)))
+	h := sha256.New()
+	_, _ = h.Write(length[:])
+	_, _ = h.Write(identifier)
+	_, _ = h.Write(serialNumber)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// MemoryStore is a Store keeping all containers in memory, e.g. for tests. Containers are stored in
+// serialized form, so changes to a container after Put or Get do not affect the stored data.
+// The zero value is ready to use and it is safe for concurrent use.
+type MemoryStore struct {
+	mu         sync.RWMutex
+	containers map[string][]byte
+}
+
+// NewMemoryStore creates a new, empty *MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Get returns the container with the given identifier and serial number
+func (s *MemoryStore) Get(identifier, serialNumber []byte) (*Container, error) {
+	s.mu.RLock()
+	b, ok := s.containers[storeKey(identifier, serialNumber)]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	c := New()
+	if err := UnmarshalBytes(b, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Put adds the container to the store. A container which does not fit into the header is rejected.
+func (s *MemoryStore) Put(c *Container) error {
+	b, err := c.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.containers == nil {
+		s.containers = make(map[string][]byte)
+	}
+	s.containers[storeKey(c.fields[FieldIdentifier], c.fields[FieldSerialNumber])] = b
+	return nil
+}
+
+// Delete removes the container with the given identifier and serial number
+func (s *MemoryStore) Delete(identifier, serialNumber []byte) error {
+	key := storeKey(identifier, serialNumber)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.containers[key]; !ok {
+		return ErrNotFound
+	}
+	delete(s.containers, key)
+	return nil
+}
+
+// List returns all containers in the store
+func (s *MemoryStore) List() ([]*Container, error) {
+	return s.Find(Query{})
+}
+
+// Find returns all containers matching the query
+func (s *MemoryStore) Find(q Query) ([]*Container, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]string, 0, len(s.containers))
+	for key := range s.containers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make([]*Container, 0)
+	for _, key := range keys {
+		c := New()
+		if err := UnmarshalBytes(s.containers[key], c); err != nil {
+			return nil, err
+		}
+		if q.Matches(c) {
+			result = append(result, c)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,165 @@
+package eraf
+
+import (
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testStore(t *testing.T, s Store) {
+	t.Helper()
+
+	var (
+		alice = New().SetIdentifier([]byte("alice")).SetSerialNumber([]byte{1}).
+			SetEmail([]byte("alice@example.com")).SetUsername([]byte("alice")).
+			SetPassword([]byte("secret")).SetRootCertificate([]byte("root"))
+		alice2 = New().SetIdentifier([]byte("alice")).SetSerialNumber([]byte{2}).
+			SetEmail([]byte("alice@example.com")).SetUsername([]byte("alice2"))
+		bob = New().SetIdentifier([]byte("bob")).SetSerialNumber([]byte{1}).
+			SetEmail([]byte("bob@example.com")).SetUsername([]byte("bob"))
+	)
+
+	for _, c := range []*Container{alice, alice2, bob} {
+		if err := s.Put(c); err != nil {
+			t.Fatalf("could not put container: %s", err.Error())
+		}
+	}
+
+	got, err := s.Get([]byte("alice"), []byte{1})
+	if err != nil {
+		t.Fatalf("could not get container: %s", err.Error())
+	}
+	if string(got.GetPassword()) != "secret" || string(got.GetRootCertificate()) != "root" {
+		t.Errorf("expected all fields to be stored, got password '%s' and root certificate '%s'",
+			got.GetPassword(), got.GetRootCertificate())
+	}
+
+	if _, err = s.Get([]byte("carol"), []byte{1}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	// an incompressible value exceeding 65,535 bytes does not fit into the header
+	oversized := New().SetIdentifier([]byte("carol")).SetSerialNumber([]byte{1})
+	_ = oversized.SetCompression(FieldCertificate, true)
+	cert := make([]byte, blockMaxSize+1000)
+	_, _ = rand.Read(cert)
+	oversized.SetCertificate(cert)
+	if err = s.Put(oversized); err == nil {
+		t.Errorf("expected error for oversized container")
+	}
+	if _, err = s.Get([]byte("carol"), []byte{1}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected oversized container not to be stored, got %v", err)
+	}
+
+	all, err := s.List()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(all) != 3 {
+		t.Errorf("expected 3 containers, got %d", len(all))
+	}
+
+	tests := []struct {
+		name  string
+		query Query
+		want  int
+	}{
+		{name: "by identifier", query: Query{Identifier: []byte("alice")}, want: 2},
+		{name: "by serial number", query: Query{SerialNumber: []byte{1}}, want: 2},
+		{name: "by email", query: Query{Email: []byte("bob@example.com")}, want: 1},
+		{name: "by username", query: Query{Username: []byte("alice2")}, want: 1},
+		{name: "combined", query: Query{Identifier: []byte("alice"), Username: []byte("alice")}, want: 1},
+		{name: "no match", query: Query{Email: []byte("nobody@example.com")}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, err := s.Find(tt.query)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			if len(found) != tt.want {
+				t.Errorf("expected %d containers, got %d", tt.want, len(found))
+			}
+		})
+	}
+
+	// replace
+	if err = s.Put(New().SetIdentifier([]byte("bob")).SetSerialNumber([]byte{1}).SetUsername([]byte("robert"))); err != nil {
+		t.Fatal(err.Error())
+	}
+	found, err := s.Find(Query{Username: []byte("robert")})
+	if err != nil || len(found) != 1 {
+		t.Errorf("expected replaced container to be found, got %d containers and error %v", len(found), err)
+	}
+
+	if err = s.Delete([]byte("bob"), []byte{1}); err != nil {
+		t.Fatalf("could not delete container: %s", err.Error())
+	}
+	if err = s.Delete([]byte("bob"), []byte{1}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if all, _ = s.List(); len(all) != 2 {
+		t.Errorf("expected 2 containers after deletion, got %d", len(all))
+	}
+}
+
+func Test_MemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func Test_DirStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "store")
+	s, err := NewDirStore(dir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	testStore(t, s)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if perm := info.Mode().Perm(); perm&0077 != 0 {
+			t.Errorf("expected file %s to be private, got mode %o", entry.Name(), perm)
+		}
+	}
+
+	// reopening builds the index from the existing files
+	s2, err := NewDirStore(dir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	found, err := s2.Find(Query{Identifier: []byte("alice")})
+	if err != nil || len(found) != 2 {
+		t.Errorf("expected 2 containers after reopening, got %d and error %v", len(found), err)
+	}
+}
+
+func Test_DirStore_ExistingFiles(t *testing.T) {
+	dir := t.TempDir()
+	c := New().SetIdentifier([]byte("device-1")).SetSerialNumber([]byte{7})
+	if err := c.MarshalToFile(filepath.Join(dir, "device.eraf"), 0600); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	s, err := NewDirStore(dir)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err = s.Get([]byte("device-1"), []byte{7}); err != nil {
+		t.Errorf("expected existing file to be indexed, got error %v", err)
+	}
+	if err = s.Delete([]byte("device-1"), []byte{7}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err = os.Stat(filepath.Join(dir, "device.eraf")); !os.IsNotExist(err) {
+		t.Errorf("expected existing file to be removed")
+	}
+}
@@ -0,0 +1,45 @@
+package eraf
+
+import "fmt"
+
+// ValidateOption enables additional checks performed by Validate
+type ValidateOption func(*validateConfig)
+
+type validateConfig struct {
+	replayGuard *ReplayGuard
+}
+
+// WithReplayGuard makes Validate reject containers whose pair of serial number and nonce has already been
+// seen by the given guard. Containers without a nonce are rejected with ErrMissingNonce. Since the pair is
+// recorded, Validate has to be called after the container has been authenticated, i.e. decrypted or its
+// signature verified; encrypted containers are rejected with ErrAlreadyEncrypted. Otherwise a forged
+// container could burn the nonce of a legitimate one.
+func WithReplayGuard(g *ReplayGuard) ValidateOption {
+	return func(cfg *validateConfig) {
+		cfg.replayGuard = g
+	}
+}
+
+// Validate checks the container for consistency, e.g. after receiving it from a remote party.
+// The checks that are performed can be extended using options.
+func (c *Container) Validate(opts ...ValidateOption) error {
+	var cfg validateConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	if err := c.checkSize(); err != nil {
+		return err
+	}
+
+	if cfg.replayGuard != nil {
+		if c.IsEncrypted() {
+			return fmt.Errorf("cannot check for replays before decryption: container is %w", ErrAlreadyEncrypted)
+		}
+		if err := cfg.replayGuard.Check(c.fields[FieldSerialNumber], c.fields[FieldNonce]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
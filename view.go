@@ -0,0 +1,184 @@
+package eraf
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// View is a read-only view of a marshalled container. The header is validated once by NewView, afterwards all
+// fields are returned as sub-slices of the underlying buffer without copying or allocating. The buffer must not
+// be modified while the View is in use; use ToContainer to obtain an independent copy.
+//
+// Fields are returned as they are stored: encrypted fields are returned encrypted and compressed fields are
+// returned compressed, see IsCompressed. Use ToContainer to decompress them.
+type View struct {
+	b          []byte
+	headers    []byte
+	payload    []byte
+	meta       []byte
+	extensions []byte
+}
+
+// NewView validates the header, the checksum trailer and the extension area of the marshalled container b
+func NewView(b []byte) (View, error) {
+	headers, payload, _, err := frame(b, false)
+	if err != nil {
+		return View{}, err
+	}
+	v := View{
+		b:       b,
+		headers: headers,
+		payload: payload,
+		meta:    payload[int(headers[0])+versionSize : int(headers[0])+int(headers[1])],
+	}
+
+	if metaFlags(v.meta)&flagExtensions != 0 {
+		if v.extensions, err = extensionEntries(payload[fieldsEnd(headers):]); err != nil {
+			return View{}, err
+		}
+		for rest := v.extensions; len(rest) > 0; {
+			if _, rest, err = nextExtension(rest); err != nil {
+				return View{}, err
+			}
+		}
+		if err = checkExtensionKeys(v.extensions); err != nil {
+			return View{}, err
+		}
+	}
+	return v, nil
+}
+
+// VersionMajor returns the major version
+func (v View) VersionMajor() byte {
+	return v.payload[int(v.headers[0])]
+}
+
+// VersionMinor returns the minor version
+func (v View) VersionMinor() byte {
+	return v.payload[int(v.headers[0])+1]
+}
+
+// VersionPatch returns the patch version
+func (v View) VersionPatch() byte {
+	return v.payload[int(v.headers[0])+2]
+}
+
+// SemVer returns the version in semantic versioning format, e.g. 1.2.3
+func (v View) SemVer() string {
+	return fmt.Sprintf("%d.%d.%d", v.VersionMajor(), v.VersionMinor(), v.VersionPatch())
+}
+
+// Get returns the given field as stored, nil if it is absent or the FieldID is unknown
+func (v View) Get(id FieldID) []byte {
+	if !id.valid() {
+		return nil
+	}
+	at := 2 + 4*int(id)
+	position := int(binary.BigEndian.Uint16(v.headers[at : at+2]))
+	length := int(binary.BigEndian.Uint16(v.headers[at+2 : at+4]))
+	if length == 0 && !v.presentEmpty(id) {
+		return nil
+	}
+	return v.payload[position : position+length : position+length]
+}
+
+// Has reports whether the given field is present
+func (v View) Has(id FieldID) bool {
+	return v.Get(id) != nil
+}
+
+func (v View) presentEmpty(id FieldID) bool {
+	return len(v.meta) >= presenceSize && binary.BigEndian.Uint16(v.meta)&(1<<id) != 0
+}
+
+// IsEncrypted reports whether the given field is encrypted according to the header flags
+func (v View) IsEncrypted(id FieldID) bool {
+	return id.valid() && metaFlags(v.meta)&(1<<id) != 0
+}
+
+// IsCompressed reports whether the given field is stored compressed
+func (v View) IsCompressed(id FieldID) bool {
+	return id.valid() && metaCompressed(v.meta)&(1<<id) != 0
+}
+
+// GetExtension returns the value of the extension with the given key and whether it exists
+func (v View) GetExtension(key ExtensionKey) ([]byte, bool) {
+	for rest := v.extensions; len(rest) > 0; {
+		// validated by NewView
+		raw, next, _ := nextExtension(rest)
+		if raw.matches(key) {
+			return raw.value, true
+		}
+		rest = next
+	}
+	return nil, false
+}
+
+// ToContainer copies the viewed data into a new *Container
+func (v View) ToContainer() (*Container, error) {
+	c := New()
+	if err := UnmarshalBytes(v.b, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Nonce returns the nonce
+func (v View) Nonce() []byte {
+	return v.Get(FieldNonce)
+}
+
+// Tag returns the tag
+func (v View) Tag() []byte {
+	return v.Get(FieldTag)
+}
+
+// SerialNumber returns the serial number
+func (v View) SerialNumber() []byte {
+	return v.Get(FieldSerialNumber)
+}
+
+// Identifier returns the identifier
+func (v View) Identifier() []byte {
+	return v.Get(FieldIdentifier)
+}
+
+// Certificate returns the certificate
+func (v View) Certificate() []byte {
+	return v.Get(FieldCertificate)
+}
+
+// PrivateKey returns the private key
+func (v View) PrivateKey() []byte {
+	return v.Get(FieldPrivateKey)
+}
+
+// Email returns the email address
+func (v View) Email() []byte {
+	return v.Get(FieldEmail)
+}
+
+// Username returns the username
+func (v View) Username() []byte {
+	return v.Get(FieldUsername)
+}
+
+// Token returns the token
+func (v View) Token() []byte {
+	return v.Get(FieldToken)
+}
+
+// Signature returns the signature
+func (v View) Signature() []byte {
+	return v.Get(FieldSignature)
+}
+
+// RootCertificate returns the root certificate
+func (v View) RootCertificate() []byte {
+	return v.Get(FieldRootCertificate)
+}
+
+// Password returns the password
+func (v View) Password() []byte {
+	return v.Get(FieldPassword)
+}
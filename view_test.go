@@ -0,0 +1,127 @@
+package eraf
+
+import (
+	"bytes"
+	"testing"
+)
+
+func viewContainer() *Container {
+	c := New().SetVersionMajor(1).SetVersionMinor(2).SetVersionPatch(3).
+		SetNonce([]byte("nonce")).SetSerialNumber([]byte{4, 2}).SetIdentifier([]byte("device-42")).
+		SetCertificate([]byte("-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n")).
+		SetEmail([]byte("someone@example.com")).SetUsername([]byte{}).SetToken([]byte("token"))
+	_ = c.SetExtension(Extension{Key: StringKey("tenant"), Value: []byte("acme")})
+	_ = c.SetExtension(Extension{Key: NumericKey(7), Value: []byte("seven")})
+	return c.SetChecksum(ChecksumCRC32C)
+}
+
+func Test_View(t *testing.T) {
+	c := viewContainer()
+	b := c.MarshalBytes()
+	v, err := NewView(b)
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+
+	if v.SemVer() != "1.2.3" {
+		t.Errorf("expected version 1.2.3, got %s", v.SemVer())
+	}
+	for _, f := range c.Fields() {
+		if !bytes.Equal(v.Get(f.ID), f.Value) || v.Has(f.ID) != c.Has(f.ID) {
+			t.Errorf("expected field %s to equal the container", f.Name)
+		}
+	}
+	if v.Username() == nil || v.Password() != nil {
+		t.Errorf("expected presence to be preserved")
+	}
+	if x, ok := v.GetExtension(StringKey("tenant")); !ok || string(x) != "acme" {
+		t.Errorf("expected extension tenant, got '%s'", x)
+	}
+	if x, ok := v.GetExtension(NumericKey(7)); !ok || string(x) != "seven" {
+		t.Errorf("expected extension #7, got '%s'", x)
+	}
+	if _, ok := v.GetExtension(StringKey("7")); ok {
+		t.Errorf("expected no extension")
+	}
+
+	// fields are sub-slices of the buffer
+	email := v.Email()
+	copied, err := v.ToContainer()
+	if err != nil {
+		t.Fatalf("expected no error, got %s", err.Error())
+	}
+	email[0] = 'S'
+	if string(v.Email()) != "Someone@example.com" {
+		t.Errorf("expected view to share the buffer")
+	}
+	if string(copied.GetEmail()) != "someone@example.com" || !bytes.Equal(copied.CanonicalBytes(), c.CanonicalBytes()) {
+		t.Errorf("expected ToContainer to copy")
+	}
+	if _, err = NewView(b); err == nil {
+		t.Errorf("expected checksum mismatch after modification")
+	}
+}
+
+func Test_View_Invalid(t *testing.T) {
+	b := viewContainer().SetChecksum(ChecksumNone).MarshalBytes()
+	tests := []struct {
+		name string
+		b    []byte
+	}{
+		{"empty", nil},
+		{"header only", b[:headerSize]},
+		{"truncated extensions", b[:len(b)-1]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewView(tt.b); err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
+
+func Test_View_Allocations(t *testing.T) {
+	b := viewContainer().MarshalBytes()
+	allocs := testing.AllocsPerRun(100, func() {
+		v, err := NewView(b)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		_, _ = v.Email(), v.Certificate()
+		_, _ = v.GetExtension(StringKey("tenant"))
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations, got %.1f", allocs)
+	}
+}
+
+func Benchmark_NewView(b *testing.B) {
+	s := viewContainer().SetChecksum(ChecksumNone).MarshalBytes()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		v, err := NewView(s)
+		if err != nil {
+			b.Fatal(err.Error())
+		}
+		_, _ = v.Email(), v.Certificate()
+	}
+}
+
+func Benchmark_UnmarshalBytes_Fields(b *testing.B) {
+	var (
+		s = viewContainer().SetChecksum(ChecksumNone).MarshalBytes()
+		c = New()
+	)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := UnmarshalBytes(s, c); err != nil {
+			b.Fatal(err.Error())
+		}
+		_, _ = c.GetEmail(), c.GetCertificate()
+	}
+}